@@ -121,3 +121,14 @@ Helper functions for auto detecting Prometheus alertmanager namespace
         {{- .Values.prometheus.endpoint }}
      {{- end }}
 {{- end }}
+
+{{/*
+Alertmanager endpoint
+*/}}
+{{- define "prometheus-am-service.endpoint" }}
+     {{- if and (.Values.prometheus.autodetect_am) (eq ((.Values.prometheus).endpoint_am | default "") "") }}
+        {{- printf "%s.%s.%s" "http://prometheus-alertmanager" (include  "prometheus-am-service.namespace" .) "svc.cluster.local:80" }}
+     {{- else }}
+        {{- .Values.prometheus.endpoint_am }}
+     {{- end }}
+{{- end }}
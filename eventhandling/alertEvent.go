@@ -67,73 +67,144 @@ type remediationTriggeredEventData struct {
 	Deployment keptnv2.DeploymentFinishedData `json:"deployment"`
 }
 
-// ProcessAndForwardAlertEvent reads the payload from the request and sends a valid Cloud event to the keptn event broker
-func ProcessAndForwardAlertEvent(rw http.ResponseWriter, requestBody []byte, logger *keptn.Logger, shkeptncontext string) {
+// alertForwardingResult summarizes which alerts of an Alertmanager notification have been forwarded to Keptn
+type alertForwardingResult struct {
+	Status    string `json:"status"`
+	Forwarded int    `json:"forwarded"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+}
+
+// sendRemediationTriggeredEvent sends the remediation.triggered event of an alert to Keptn
+var sendRemediationTriggeredEvent = createAndSendCE
+
+// ProcessAndForwardAlertEvent reads the payload from the request and sends a remediation.triggered event to the keptn
+// event broker for each distinct project, stage, service and alert name of the firing alerts. The notification is only
+// rejected if no alert could be sent, since Alertmanager re-sends the whole notification, which would start the
+// remediations of the already forwarded alerts again.
+func ProcessAndForwardAlertEvent(rw http.ResponseWriter, requestBody []byte, logger *keptn.Logger) {
 	var event alertManagerEvent
 
 	logger.Info("Received alert from Prometheus Alertmanager:" + string(requestBody))
 	err := json.Unmarshal(requestBody, &event)
 	if err != nil {
 		logger.Error("Could not map received event to datastructure: " + err.Error())
+		writeAlertForwardingResult(rw, http.StatusBadRequest, alertForwardingResult{Status: "invalid payload"})
 		return
 	}
 
-	problemState := ""
-	if event.Status == "firing" {
-		problemState = "OPEN"
-	} else if event.Status == "resolved" {
-		logger.Info("Don't forward resolved problem.")
+	if len(event.Alerts) == 0 {
+		logger.Error("Received alert event without alerts")
+		writeAlertForwardingResult(rw, http.StatusBadRequest, alertForwardingResult{Status: "no alerts"})
 		return
 	}
 
+	result := alertForwardingResult{}
+	forwarded := map[string]bool{}
+
+	for _, alert := range event.Alerts {
+		status := alert.Status
+		if status == "" {
+			status = event.Status
+		}
+		if status != "firing" {
+			logger.Info(fmt.Sprintf("Don't forward %s problem %s.", status, alert.Labels.AlertName))
+			result.Skipped++
+			continue
+		}
+
+		if alert.Labels.Project == "" || alert.Labels.Stage == "" || alert.Labels.Service == "" {
+			logger.Error(fmt.Sprintf("Alert %s does not contain the labels project, stage and service, skipping it", alert.Labels.AlertName))
+			result.Skipped++
+			continue
+		}
+
+		// Alertmanager may group several alerts of the same service, e.g. of different pods, in one notification
+		key := alert.Labels.Project + "/" + alert.Labels.Stage + "/" + alert.Labels.Service + "/" + alert.Labels.AlertName
+		if forwarded[key] {
+			result.Skipped++
+			continue
+		}
+		forwarded[key] = true
+
+		keptnContext := uuid.New().String()
+		if alert.Fingerprint != "" {
+			// Note: fingerprint is always the same, we will append the startdate to create a unique keptn context
+			keptnContext = createOrApplyKeptnContext(alert.Fingerprint + alert.StartsAt)
+		}
+		logger.Debug("shkeptncontext=" + keptnContext)
+
+		logger.Debug("Sending event to eventbroker")
+		if err := sendRemediationTriggeredEvent(newRemediationTriggeredEventData(alert), keptnContext); err != nil {
+			logger.Error(fmt.Sprintf("Could not send cloud event for alert %s: %s", key, err.Error()))
+			result.Failed++
+			continue
+		}
+		logger.Debug("event successfully dispatched to eventbroker")
+		result.Forwarded++
+	}
+
+	switch {
+	case result.Failed > 0 && result.Forwarded == 0:
+		result.Status = "alerts not forwarded"
+		writeAlertForwardingResult(rw, http.StatusInternalServerError, result)
+	case result.Failed > 0:
+		logger.Error(fmt.Sprintf("Forwarded %d alerts, %d alerts could not be sent", result.Forwarded, result.Failed))
+		result.Status = "not all alerts forwarded"
+		writeAlertForwardingResult(rw, http.StatusCreated, result)
+	case result.Forwarded > 0:
+		result.Status = "alerts forwarded"
+		writeAlertForwardingResult(rw, http.StatusCreated, result)
+	default:
+		result.Status = "no alerts forwarded"
+		writeAlertForwardingResult(rw, http.StatusOK, result)
+	}
+}
+
+// newRemediationTriggeredEventData returns the data of the remediation.triggered event of a firing alert
+func newRemediationTriggeredEventData(firingAlert alert) remediationTriggeredEventData {
+	problemDetails, _ := json.Marshal(map[string]string{"problemDetails": firingAlert.Annotations.Description})
+
 	problemData := keptncommons.ProblemEventData{
-		State:          problemState,
+		State:          "OPEN",
 		ProblemID:      "",
-		ProblemTitle:   event.Alerts[0].Annotations.Summary,
-		ProblemDetails: json.RawMessage(`{"problemDetails":"` + event.Alerts[0].Annotations.Description + `"}`),
-		ProblemURL:     event.Alerts[0].GeneratorURL,
-		ImpactedEntity: event.Alerts[0].Labels.PodName,
-		Project:        event.Alerts[0].Labels.Project,
-		Stage:          event.Alerts[0].Labels.Stage,
-		Service:        event.Alerts[0].Labels.Service,
+		ProblemTitle:   firingAlert.Annotations.Summary,
+		ProblemDetails: problemDetails,
+		ProblemURL:     firingAlert.GeneratorURL,
+		ImpactedEntity: firingAlert.Labels.PodName,
+		Project:        firingAlert.Labels.Project,
+		Stage:          firingAlert.Labels.Stage,
+		Service:        firingAlert.Labels.Service,
 		Labels: map[string]string{
-			"deployment": event.Alerts[0].Labels.Deployment,
+			"deployment": firingAlert.Labels.Deployment,
 		},
 	}
 
-	newEventData := remediationTriggeredEventData{
+	return remediationTriggeredEventData{
 		EventData: keptnv2.EventData{
-			Project: event.Alerts[0].Labels.Project,
-			Stage:   event.Alerts[0].Labels.Stage,
-			Service: event.Alerts[0].Labels.Service,
+			Project: firingAlert.Labels.Project,
+			Stage:   firingAlert.Labels.Stage,
+			Service: firingAlert.Labels.Service,
 			Labels: map[string]string{
-				"Problem URL": event.Alerts[0].GeneratorURL,
+				"Problem URL": firingAlert.GeneratorURL,
 			},
 		},
 		Problem: problemData,
 		Deployment: keptnv2.DeploymentFinishedData{
 			DeploymentNames: []string{
-				event.Alerts[0].Labels.Deployment,
+				firingAlert.Labels.Deployment,
 			},
 		},
 	}
+}
 
-	if event.Alerts[0].Fingerprint != "" {
-		// Note: fingerprint is always the same, we will append the startdate to create a unique keptn context
-		shkeptncontext = createOrApplyKeptnContext(event.Alerts[0].Fingerprint + event.Alerts[0].StartsAt)
-		logger.Debug("shkeptncontext=" + shkeptncontext)
-	} else {
-		logger.Debug("NO SHKEPTNCONTEXT SET")
-	}
+func writeAlertForwardingResult(rw http.ResponseWriter, statusCode int, result alertForwardingResult) {
+	body, _ := json.Marshal(result)
 
-	logger.Debug("Sending event to eventbroker")
-	err = createAndSendCE(newEventData, shkeptncontext)
-	if err != nil {
-		logger.Error("Could not send cloud event: " + err.Error())
-		rw.WriteHeader(500)
-	} else {
-		logger.Debug("event successfully dispatched to eventbroker")
-		rw.WriteHeader(201)
+	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(statusCode)
+	if _, err := rw.Write(body); err != nil {
+		log.Println(err)
 	}
 }
 
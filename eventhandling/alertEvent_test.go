@@ -0,0 +1,128 @@
+package eventhandling
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/lib/keptn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAlertManagerEvent = `{
+  "receiver": "keptn_integration",
+  "status": "firing",
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "response_time_p95", "pod_name": "carts-primary-1", "project": "sockshop", "stage": "production", "service": "carts", "deployment": "primary"},
+      "annotations": {"summary": "response_time_p95", "descriptions": "Response time of \"carts\" degraded"},
+      "fingerprint": "0c8e2ab0ed6a7ba4",
+      "startsAt": "2022-06-01T10:00:00Z"
+    },
+    {
+      "status": "firing",
+      "labels": {"alertname": "response_time_p95", "pod_name": "carts-primary-2", "project": "sockshop", "stage": "production", "service": "carts", "deployment": "primary"},
+      "annotations": {"summary": "response_time_p95"},
+      "fingerprint": "1d9f3bc1fe7b8cb5",
+      "startsAt": "2022-06-01T10:00:00Z"
+    },
+    {
+      "status": "firing",
+      "labels": {"alertname": "error_rate", "project": "sockshop", "stage": "production", "service": "orders", "deployment": "primary"},
+      "annotations": {"summary": "error_rate"}
+    },
+    {
+      "status": "resolved",
+      "labels": {"alertname": "error_rate", "project": "sockshop", "stage": "dev", "service": "carts", "deployment": "direct"},
+      "annotations": {"summary": "error_rate"}
+    }
+  ]
+}`
+
+func stubRemediationTriggeredEvents(t *testing.T, err error) *[]remediationTriggeredEventData {
+	original := sendRemediationTriggeredEvent
+	t.Cleanup(func() { sendRemediationTriggeredEvent = original })
+
+	var sent []remediationTriggeredEventData
+	sendRemediationTriggeredEvent = func(data remediationTriggeredEventData, shkeptncontext string) error {
+		sent = append(sent, data)
+		return err
+	}
+	return &sent
+}
+
+func processTestAlertEvent(t *testing.T, body string) (int, alertForwardingResult) {
+	recorder := httptest.NewRecorder()
+	ProcessAndForwardAlertEvent(recorder, []byte(body), keptn.NewLogger("", "", "prometheus-service"))
+
+	var result alertForwardingResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	return recorder.Code, result
+}
+
+func TestProcessAndForwardAlertEvent(t *testing.T) {
+	sent := stubRemediationTriggeredEvents(t, nil)
+
+	code, result := processTestAlertEvent(t, testAlertManagerEvent)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, alertForwardingResult{Status: "alerts forwarded", Forwarded: 2, Skipped: 2}, result)
+
+	require.Len(t, *sent, 2)
+	assert.Equal(t, "carts", (*sent)[0].Service)
+	assert.Equal(t, "response_time_p95", (*sent)[0].Problem.ProblemTitle)
+	assert.Equal(t, "carts-primary-1", (*sent)[0].Problem.ImpactedEntity)
+	assert.JSONEq(t, `{"problemDetails":"Response time of \"carts\" degraded"}`, string((*sent)[0].Problem.ProblemDetails))
+	assert.Equal(t, "orders", (*sent)[1].Service)
+	assert.Equal(t, "error_rate", (*sent)[1].Problem.ProblemTitle)
+}
+
+func TestProcessAndForwardAlertEventWithoutAlerts(t *testing.T) {
+	sent := stubRemediationTriggeredEvents(t, nil)
+
+	code, result := processTestAlertEvent(t, `{"receiver": "keptn_integration", "status": "firing", "alerts": []}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "no alerts", result.Status)
+	assert.Empty(t, *sent)
+
+	code, _ = processTestAlertEvent(t, `{"alerts": "invalid"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestProcessAndForwardAlertEventResolved(t *testing.T) {
+	sent := stubRemediationTriggeredEvents(t, nil)
+
+	code, result := processTestAlertEvent(t, `{"status": "resolved", "alerts": [{"labels": {"alertname": "error_rate", "project": "sockshop", "stage": "dev", "service": "carts"}}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, alertForwardingResult{Status: "no alerts forwarded", Skipped: 1}, result)
+	assert.Empty(t, *sent)
+}
+
+func TestProcessAndForwardAlertEventSendFailure(t *testing.T) {
+	stubRemediationTriggeredEvents(t, errors.New("nats unavailable"))
+
+	// the notification can be retried since no alert has been forwarded
+	code, result := processTestAlertEvent(t, testAlertManagerEvent)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, 0, result.Forwarded)
+}
+
+func TestProcessAndForwardAlertEventPartialFailure(t *testing.T) {
+	original := sendRemediationTriggeredEvent
+	defer func() { sendRemediationTriggeredEvent = original }()
+	sendRemediationTriggeredEvent = func(data remediationTriggeredEventData, shkeptncontext string) error {
+		if data.Service == "orders" {
+			return errors.New("nats unavailable")
+		}
+		return nil
+	}
+
+	// a retry of the notification would start the remediation of the forwarded alert again
+	code, result := processTestAlertEvent(t, testAlertManagerEvent)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, alertForwardingResult{Status: "not all alerts forwarded", Forwarded: 1, Skipped: 2, Failed: 1}, result)
+}
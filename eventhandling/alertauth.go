@@ -0,0 +1,61 @@
+package eventhandling
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// AlertWebhookAuth verifies the credentials of the requests sent by the Alertmanager to the alert endpoint
+type AlertWebhookAuth struct {
+	// Type is the type of the required credentials (bearer or basic), all requests are accepted if it is empty
+	Type     string
+	Username string
+	// Token is the bearer token or the password of the basic auth
+	Token string
+}
+
+// NewAlertWebhookAuth returns the verification of the alert endpoint configured by the ALERT_WEBHOOK_* env vars
+func NewAlertWebhookAuth(envConfig utils.EnvConfig) (AlertWebhookAuth, error) {
+	auth := AlertWebhookAuth{
+		Type:     strings.ToLower(envConfig.AlertWebhookAuth),
+		Username: envConfig.AlertWebhookUsername,
+		Token:    envConfig.AlertWebhookToken,
+	}
+
+	switch auth.Type {
+	case "":
+		return auth, nil
+	case prometheus.AlertManagerAuthBearer, prometheus.AlertManagerAuthBasic:
+		if auth.Token == "" {
+			return auth, errors.New("ALERT_WEBHOOK_TOKEN is required if ALERT_WEBHOOK_AUTH is set")
+		}
+		return auth, nil
+	}
+	return auth, fmt.Errorf("invalid value %s for ALERT_WEBHOOK_AUTH", envConfig.AlertWebhookAuth)
+}
+
+// Authenticate returns true if the request contains the required credentials
+func (a AlertWebhookAuth) Authenticate(r *http.Request) bool {
+	switch a.Type {
+	case prometheus.AlertManagerAuthBearer:
+		header := r.Header.Get("Authorization")
+		return strings.HasPrefix(header, "Bearer ") && secureCompare(strings.TrimPrefix(header, "Bearer "), a.Token)
+	case prometheus.AlertManagerAuthBasic:
+		username, password, ok := r.BasicAuth()
+		// both values are compared to avoid revealing which one is wrong by the response time
+		usernameMatches := secureCompare(username, a.Username)
+		passwordMatches := secureCompare(password, a.Token)
+		return ok && usernameMatches && passwordMatches
+	}
+	return true
+}
+
+func secureCompare(given string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
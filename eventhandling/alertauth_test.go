@@ -0,0 +1,55 @@
+package eventhandling
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+)
+
+func TestAlertWebhookAuth_AuthenticateBearer(t *testing.T) {
+	auth, err := NewAlertWebhookAuth(utils.EnvConfig{AlertWebhookAuth: "Bearer", AlertWebhookToken: "s3cr3t"})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest("POST", "/", nil)
+	assert.False(t, auth.Authenticate(request))
+
+	request.Header.Set("Authorization", "s3cr3t")
+	assert.False(t, auth.Authenticate(request))
+
+	request.Header.Set("Authorization", "Bearer wrong")
+	assert.False(t, auth.Authenticate(request))
+
+	request.Header.Set("Authorization", "Bearer s3cr3t")
+	assert.True(t, auth.Authenticate(request))
+}
+
+func TestAlertWebhookAuth_AuthenticateBasic(t *testing.T) {
+	auth, err := NewAlertWebhookAuth(utils.EnvConfig{AlertWebhookAuth: "basic", AlertWebhookUsername: "keptn", AlertWebhookToken: "s3cr3t"})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest("POST", "/", nil)
+	assert.False(t, auth.Authenticate(request))
+
+	request.SetBasicAuth("admin", "s3cr3t")
+	assert.False(t, auth.Authenticate(request))
+
+	request.SetBasicAuth("keptn", "s3cr3t")
+	assert.True(t, auth.Authenticate(request))
+}
+
+func TestNewAlertWebhookAuth(t *testing.T) {
+	// all requests are accepted without authentication
+	auth, err := NewAlertWebhookAuth(utils.EnvConfig{})
+	require.NoError(t, err)
+	assert.True(t, auth.Authenticate(httptest.NewRequest("POST", "/", nil)))
+
+	_, err = NewAlertWebhookAuth(utils.EnvConfig{AlertWebhookAuth: "bearer"})
+	assert.Error(t, err)
+
+	_, err = NewAlertWebhookAuth(utils.EnvConfig{AlertWebhookAuth: "digest", AlertWebhookToken: "s3cr3t"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package eventhandling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// getAlertManagerIntegration returns the receiver and route forwarding alerts to the prometheus-service running in the
+// given namespace as configured by the ALERT_MANAGER_* env vars
+func getAlertManagerIntegration(namespace string) (prometheus.AlertManagerIntegration, error) {
+	integration := prometheus.NewAlertManagerIntegration(namespace)
+	if env.AlertManagerReceiver != "" {
+		integration.Receiver = env.AlertManagerReceiver
+	}
+	integration.GroupWait = env.AlertManagerGroupWait
+	integration.RepeatInterval = env.AlertManagerRepeatInterval
+
+	matchers, err := prometheus.ParseAlertManagerMatchers(env.AlertManagerMatchers)
+	if err != nil {
+		return integration, fmt.Errorf("invalid ALERT_MANAGER_MATCHERS %q: %w", env.AlertManagerMatchers, err)
+	}
+	integration.Matchers = matchers
+
+	integration.GroupBy = nil
+	for _, label := range strings.Split(env.AlertManagerGroupBy, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			integration.GroupBy = append(integration.GroupBy, label)
+		}
+	}
+
+	auth, err := NewAlertWebhookAuth(env)
+	if err != nil {
+		return integration, err
+	}
+	integration.AuthType = auth.Type
+	integration.Username = auth.Username
+	integration.Token = auth.Token
+	integration.CAFile = env.AlertWebhookCAFile
+	if alertWebhookTLSEnabled(env) {
+		integration.WebhookURL = strings.Replace(integration.WebhookURL, "http://", "https://", 1)
+	}
+
+	return integration, nil
+}
+
+// alertWebhookTLSEnabled returns true if the alert endpoint is served via HTTPS
+func alertWebhookTLSEnabled(envConfig utils.EnvConfig) bool {
+	return envConfig.AlertWebhookTLSCertFile != "" && envConfig.AlertWebhookTLSKeyFile != ""
+}
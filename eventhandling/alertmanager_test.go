@@ -0,0 +1,54 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+)
+
+func Test_getAlertManagerIntegration(t *testing.T) {
+	defer func(original utils.EnvConfig) { env = original }(env)
+	env = utils.EnvConfig{
+		AlertManagerReceiver:       "keptn",
+		AlertManagerMatchers:       `severity=~"webhook|critical", team="checkout"`,
+		AlertManagerGroupBy:        "alertname, service",
+		AlertManagerGroupWait:      "30s",
+		AlertManagerRepeatInterval: "5m",
+	}
+
+	integration, err := getAlertManagerIntegration("keptn-system")
+	require.NoError(t, err)
+	assert.Equal(t, "keptn", integration.Receiver)
+	assert.Equal(t, "http://prometheus-service.keptn-system.svc.cluster.local:8080", integration.WebhookURL)
+	assert.Equal(t, []string{`severity=~"webhook|critical"`, `team="checkout"`}, integration.Matchers)
+	assert.Equal(t, []string{"alertname", "service"}, integration.GroupBy)
+	assert.Equal(t, "30s", integration.GroupWait)
+	assert.Equal(t, "5m", integration.RepeatInterval)
+
+	env.AlertManagerMatchers = `severity=~"("`
+	_, err = getAlertManagerIntegration("keptn-system")
+	assert.Error(t, err)
+}
+
+func Test_getAlertManagerIntegrationWebhookCredentials(t *testing.T) {
+	defer func(original utils.EnvConfig) { env = original }(env)
+	env = utils.EnvConfig{
+		AlertWebhookAuth:        "bearer",
+		AlertWebhookToken:       "s3cr3t",
+		AlertWebhookTLSCertFile: "/etc/prometheus-service/tls/tls.crt",
+		AlertWebhookTLSKeyFile:  "/etc/prometheus-service/tls/tls.key",
+	}
+
+	integration, err := getAlertManagerIntegration("keptn-system")
+	require.NoError(t, err)
+	assert.Equal(t, "https://prometheus-service.keptn-system.svc.cluster.local:8080", integration.WebhookURL)
+	assert.Equal(t, "bearer", integration.AuthType)
+	assert.Equal(t, "s3cr3t", integration.Token)
+
+	env.AlertWebhookToken = ""
+	_, err = getAlertManagerIntegration("keptn-system")
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package eventhandling
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	"github.com/keptn/go-utils/pkg/sdk"
+	prometheus_model "github.com/prometheus/common/model"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+)
+
+const alertBaselineOffsetEnvName = "ALERT_BASELINE_OFFSET"
+
+// criterionRegex matches SLO criteria like <=800, >=-5%, <+10 or =0
+var criterionRegex = regexp.MustCompile(`^(<=|>=|<|>|=)([+-]?)([0-9]*\.?[0-9]+)(%?)$`)
+
+// violatedOperators maps the operator of a pass criterion to the operator of the comparison that is true if the
+// criterion is violated
+var violatedOperators = map[string]string{
+	"<":  ">=",
+	"<=": ">",
+	">":  "<=",
+	">=": "<",
+	"=":  "!=",
+}
+
+// sloCriterion is a parsed pass criterion of an SLO objective
+type sloCriterion struct {
+	operator string
+	value    float64
+	// relative is set if the threshold is relative to the value of the baseline, e.g. <=+10% or >-5
+	relative bool
+	// percentage is set if the relative threshold is a percentage of the baseline value
+	percentage bool
+}
+
+// parseSLOCriterion parses a criterion of the format <operator>[+|-]<value>[%]. A signed value or a percentage is
+// relative to the baseline.
+func parseSLOCriterion(criterion string) (sloCriterion, error) {
+	matches := criterionRegex.FindStringSubmatch(strings.ReplaceAll(criterion, " ", ""))
+	if matches == nil {
+		return sloCriterion{}, fmt.Errorf("invalid criterion %q", criterion)
+	}
+
+	value, err := strconv.ParseFloat(matches[3], 64)
+	if err != nil {
+		return sloCriterion{}, fmt.Errorf("invalid value of criterion %q: %w", criterion, err)
+	}
+	if matches[2] == "-" {
+		value = -value
+	}
+
+	return sloCriterion{
+		operator:   matches[1],
+		value:      value,
+		relative:   matches[2] != "" || matches[4] != "",
+		percentage: matches[4] != "",
+	}, nil
+}
+
+// violationExpr returns a PromQL expression that returns the value of the SLI expression if the criterion is violated
+func (c sloCriterion) violationExpr(expr string, baselineOffset string) string {
+	operator := violatedOperators[c.operator]
+	// the parentheses apply the comparison to the whole SLI expression, e.g. if it contains a top-level or
+	sliExpr := "(" + expr + ")"
+	if !c.relative {
+		return fmt.Sprintf("%s %s %s", sliExpr, operator, formatFloat(c.value))
+	}
+
+	// the offset modifier can only be applied to selectors, the subquery applies it to the whole SLI expression
+	baseline := fmt.Sprintf("last_over_time(%s[1m:] offset %s)", sliExpr, baselineOffset)
+	if c.percentage {
+		return fmt.Sprintf("%s %s %s * %s", sliExpr, operator, formatFloat(1+c.value/100), baseline)
+	}
+	if c.value < 0 {
+		return fmt.Sprintf("%s %s %s - %s", sliExpr, operator, baseline, formatFloat(-c.value))
+	}
+	return fmt.Sprintf("%s %s %s + %s", sliExpr, operator, baseline, formatFloat(c.value))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// getAlertBaselineOffset returns the offset of the baseline that relative criteria are compared with
+func getAlertBaselineOffset(k sdk.IKeptn) string {
+	offset := utils.EnvVarOrDefault(alertBaselineOffsetEnvName, "1w")
+	if _, err := prometheus_model.ParseDuration(offset); err != nil {
+		k.Logger().Errorf("Error while converting %s value. Using default value instead!", alertBaselineOffsetEnvName)
+		return "1w"
+	}
+	return offset
+}
+
+// getAlertExpr returns the expression of an alert that fires if the objective fails, i.e., if every criteria group of
+// the pass criteria contains a violated criterion. Criteria that cannot be translated to PromQL are skipped and
+// returned. The expression is empty if any criteria group contains no translatable criterion, since the objective might
+// still pass by this group while the alert fires.
+func getAlertExpr(expr string, objective *keptnevents.SLO, baselineOffset string) (string, []string) {
+	var groupExprs []string
+	var skipped []string
+	untranslatableGroup := false
+
+	for _, criteriaGroup := range objective.Pass {
+		if criteriaGroup == nil {
+			continue
+		}
+
+		var violations []string
+		for _, criterion := range criteriaGroup.Criteria {
+			parsed, err := parseSLOCriterion(criterion)
+			if err != nil {
+				skipped = append(skipped, criterion)
+				continue
+			}
+			violations = append(violations, parsed.violationExpr(expr, baselineOffset))
+		}
+
+		switch len(violations) {
+		case 0:
+			untranslatableGroup = true
+		case 1:
+			groupExprs = append(groupExprs, violations[0])
+		default:
+			groupExprs = append(groupExprs, "("+strings.Join(violations, ") or (")+")")
+		}
+	}
+
+	if untranslatableGroup {
+		return "", skipped
+	}
+
+	switch len(groupExprs) {
+	case 0:
+		return "", skipped
+	case 1:
+		return groupExprs[0], skipped
+	default:
+		return "(" + strings.Join(groupExprs, ") and (") + ")", skipped
+	}
+}
@@ -0,0 +1,102 @@
+package eventhandling
+
+import (
+	"testing"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseSLOCriterion(t *testing.T) {
+	tests := []struct {
+		criterion string
+		want      sloCriterion
+		wantErr   bool
+	}{
+		{criterion: "<=800", want: sloCriterion{operator: "<=", value: 800}},
+		{criterion: "< 0.5", want: sloCriterion{operator: "<", value: 0.5}},
+		{criterion: "=0", want: sloCriterion{operator: "=", value: 0}},
+		{criterion: "<=+10%", want: sloCriterion{operator: "<=", value: 10, relative: true, percentage: true}},
+		{criterion: ">=-5%", want: sloCriterion{operator: ">=", value: -5, relative: true, percentage: true}},
+		{criterion: "<+50", want: sloCriterion{operator: "<", value: 50, relative: true}},
+		{criterion: "<=10%", want: sloCriterion{operator: "<=", value: 10, relative: true, percentage: true}},
+		{criterion: "800", wantErr: true},
+		{criterion: "=>800", wantErr: true},
+		{criterion: "<=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.criterion, func(t *testing.T) {
+			got, err := parseSLOCriterion(tt.criterion)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getAlertExprTopLevelOperator(t *testing.T) {
+	objective := &keptnevents.SLO{Pass: []*keptnevents.SLOCriteria{{Criteria: []string{"<=0.01", "<=+10%"}}}}
+
+	// the threshold applies to the whole SLI expression instead of the last operand of the or
+	got, _ := getAlertExpr("rate(errors_total[1m]) or vector(0)", objective, "1w")
+	assert.Equal(t, "((rate(errors_total[1m]) or vector(0)) > 0.01) or ((rate(errors_total[1m]) or vector(0)) > 1.1 * last_over_time((rate(errors_total[1m]) or vector(0))[1m:] offset 1w))", got)
+}
+
+func Test_getAlertExpr(t *testing.T) {
+	const query = "sum(rate(http_requests_total[3m]))"
+	const expr = "(" + query + ")"
+	const baseline = "last_over_time(" + expr + "[1m:] offset 1w)"
+
+	tests := []struct {
+		name        string
+		pass        []*keptnevents.SLOCriteria
+		want        string
+		wantSkipped []string
+	}{
+		{
+			name: "absolute",
+			pass: []*keptnevents.SLOCriteria{{Criteria: []string{"<=800"}}},
+			want: expr + " > 800",
+		},
+		{
+			name: "relative percentage",
+			pass: []*keptnevents.SLOCriteria{{Criteria: []string{"<=+10%"}}},
+			want: expr + " > 1.1 * " + baseline,
+		},
+		{
+			name: "relative decrease",
+			pass: []*keptnevents.SLOCriteria{{Criteria: []string{">=-5"}}},
+			want: expr + " < " + baseline + " - 5",
+		},
+		{
+			name: "criteria of a group and groups",
+			pass: []*keptnevents.SLOCriteria{{Criteria: []string{"<600", "<=+10%"}}, {Criteria: []string{"<1000"}}},
+			want: "((" + expr + " >= 600) or (" + expr + " > 1.1 * " + baseline + ")) and (" + expr + " >= 1000)",
+		},
+		{
+			name:        "untranslatable criteria",
+			pass:        []*keptnevents.SLOCriteria{{Criteria: []string{"800"}}},
+			want:        "",
+			wantSkipped: []string{"800"},
+		},
+		{
+			name:        "untranslatable criteria group",
+			pass:        []*keptnevents.SLOCriteria{{Criteria: []string{"800"}}, {Criteria: []string{"<600"}}},
+			want:        "",
+			wantSkipped: []string{"800"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped := getAlertExpr(query, &keptnevents.SLO{SLI: "throughput", Pass: tt.pass}, "1w")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantSkipped, skipped)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package eventhandling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keptn/go-utils/pkg/sdk"
+	prometheus_model "github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// alertSettingsResourceURI is the Keptn resource containing the settings of the generated alerting rules
+const alertSettingsResourceURI = "prometheus/alerts.yaml"
+
+// reservedAlertLabels contains the labels set by prometheus-service that cannot be overridden by extra labels
+var reservedAlertLabels = map[string]bool{
+	"severity": true, "pod_name": true, "service": true, "stage": true, "project": true, "deployment": true, managedLabel: true,
+}
+
+// alertSettingsResource is the content of prometheus/alerts.yaml
+type alertSettingsResource struct {
+	// Objectives contains the settings of the alerting rules of the objectives by SLI name
+	Objectives map[string]alertSettings `yaml:"objectives"`
+}
+
+// alertSettings overrides the properties of the alerting rule generated for an objective. Summary and description may
+// contain the placeholders $SLI, $THRESHOLD, $PROJECT, $STAGE and $SERVICE besides Prometheus template variables like
+// {{ $value }}.
+type alertSettings struct {
+	For         prometheus_model.Duration `yaml:"for,omitempty"`
+	Severity    string                    `yaml:"severity,omitempty"`
+	Labels      map[string]string         `yaml:"labels,omitempty"`
+	RunbookURL  string                    `yaml:"runbookURL,omitempty"`
+	Summary     string                    `yaml:"summary,omitempty"`
+	Description string                    `yaml:"description,omitempty"`
+}
+
+// merge returns the settings overridden by the values set in other
+func (s alertSettings) merge(other alertSettings) alertSettings {
+	if other.For != 0 {
+		s.For = other.For
+	}
+	if other.Severity != "" {
+		s.Severity = other.Severity
+	}
+	if len(other.Labels) > 0 {
+		labels := map[string]string{}
+		for name, value := range s.Labels {
+			labels[name] = value
+		}
+		for name, value := range other.Labels {
+			labels[name] = value
+		}
+		s.Labels = labels
+	}
+	if other.RunbookURL != "" {
+		s.RunbookURL = other.RunbookURL
+	}
+	if other.Summary != "" {
+		s.Summary = other.Summary
+	}
+	if other.Description != "" {
+		s.Description = other.Description
+	}
+	return s
+}
+
+func (s alertSettings) validate() error {
+	for name := range s.Labels {
+		if reservedAlertLabels[name] {
+			return fmt.Errorf("label %s is set by prometheus-service and cannot be overridden", name)
+		}
+		if !prometheus_model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+// getAlertSettings retrieves the alert settings of the objectives of the service considering the configuration on
+// project, stage and service level, where the configuration of a more specific level overrides the one of a less
+// specific level
+func getAlertSettings(resourceHandler sdk.ResourceHandler, project string, stage string, service string) (map[string]alertSettings, error) {
+	contents, err := getResourceContents(resourceHandler, project, stage, service, alertSettingsResourceURI)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]alertSettings{}
+	for _, content := range contents {
+		resource := alertSettingsResource{}
+		if err := yaml.UnmarshalStrict([]byte(content), &resource); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", alertSettingsResourceURI, err)
+		}
+		for sli, settings := range resource.Objectives {
+			result[sli] = result[sli].merge(settings)
+		}
+	}
+
+	for sli, settings := range result {
+		if err := settings.validate(); err != nil {
+			return nil, fmt.Errorf("invalid settings of SLI %s in %s: %w", sli, alertSettingsResourceURI, err)
+		}
+	}
+	return result, nil
+}
+
+// applyAlertSettings applies the settings to the alerting rule and replaces the placeholders of its annotations
+func applyAlertSettings(rule *alertingRule, settings alertSettings, placeholders *strings.Replacer) {
+	if settings.For != 0 {
+		rule.For = settings.For.String()
+	}
+	if settings.Severity != "" {
+		rule.Labels.Severity = settings.Severity
+	}
+	rule.Labels.Extra = settings.Labels
+
+	if settings.Summary != "" {
+		rule.Annotations.Summary = settings.Summary
+	}
+	if settings.Description != "" {
+		rule.Annotations.Description = settings.Description
+	}
+	rule.Annotations.RunbookURL = settings.RunbookURL
+
+	rule.Annotations.Summary = placeholders.Replace(rule.Annotations.Summary)
+	rule.Annotations.Description = placeholders.Replace(rule.Annotations.Description)
+}
+
+// getCriteriaThreshold returns the pass criteria of an objective as text, e.g. <=800 and <=+10%
+func getCriteriaThreshold(objective *sloObjective) string {
+	var groups []string
+	for _, criteriaGroup := range objective.Pass {
+		if criteriaGroup != nil && len(criteriaGroup.Criteria) > 0 {
+			groups = append(groups, strings.Join(criteriaGroup.Criteria, " and "))
+		}
+	}
+	return strings.Join(groups, " or ")
+}
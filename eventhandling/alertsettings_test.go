@@ -0,0 +1,96 @@
+package eventhandling
+
+import (
+	"testing"
+	"time"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	prometheus_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+func Test_getAlertSettings(t *testing.T) {
+	resourceHandler := fakeResourceHandler{resources: map[string]string{
+		"/v1/project/sockshop/resource/prometheus%2Falerts.yaml": `objectives:
+  response_time_p95:
+    for: 5m
+    labels:
+      team: checkout
+`,
+		"/v1/project/sockshop/stage/dev/service/carts/resource/prometheus%2Falerts.yaml": `objectives:
+  response_time_p95:
+    severity: critical
+    labels:
+      tier: backend
+`,
+	}}
+
+	settings, err := getAlertSettings(resourceHandler, "sockshop", "dev", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, alertSettings{
+		For:      prometheus_model.Duration(5 * time.Minute),
+		Severity: "critical",
+		Labels:   map[string]string{"team": "checkout", "tier": "backend"},
+	}, settings["response_time_p95"])
+}
+
+func Test_getAlertSettingsReservedLabel(t *testing.T) {
+	resourceHandler := fakeResourceHandler{resources: map[string]string{
+		"/v1/project/sockshop/resource/prometheus%2Falerts.yaml": `objectives:
+  response_time_p95:
+    labels:
+      service: other
+`,
+	}}
+
+	_, err := getAlertSettings(resourceHandler, "sockshop", "dev", "carts")
+	assert.Error(t, err)
+}
+
+func Test_createPrometheusAlertsWithAlertSettings(t *testing.T) {
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/stage/dev/service/carts/resource/slo.yaml": `objectives:
+  - sli: response_time_p95
+    pass:
+      - criteria:
+          - "<=200"
+    prometheus:
+      alert:
+        description: "$SLI of $SERVICE is {{ $value }}, expected $THRESHOLD"
+`,
+		"/v1/project/sockshop/stage/dev/service/carts/resource/remediation.yaml": "remediations: []",
+		"/v1/project/sockshop/resource/prometheus%2Falerts.yaml": `objectives:
+  response_time_p95:
+    for: 2m
+    labels:
+      team: checkout
+    runbookURL: https://runbooks.example.com/latency
+    description: overridden by slo.yaml
+`,
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+
+	config, err := ConfigureMonitoringEventHandler{}.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, keptnv2.Stage{Name: "dev"}, alertingRules{})
+	require.NoError(t, err)
+
+	require.Len(t, config.Groups, 1)
+	require.Len(t, config.Groups[0].Rules, 1)
+	rule := config.Groups[0].Rules[0]
+	assert.Equal(t, "2m", rule.For)
+	assert.Equal(t, "webhook", rule.Labels.Severity)
+	assert.Equal(t, map[string]string{"team": "checkout"}, rule.Labels.Extra)
+	assert.Equal(t, "response_time_p95", rule.Annotations.Summary)
+	assert.Equal(t, "response_time_p95 of carts is {{ $value }}, expected <=200", rule.Annotations.Description)
+	assert.Equal(t, "https://runbooks.example.com/latency", rule.Annotations.RunbookURL)
+
+	// the extra labels are written next to the generated labels
+	rulesYAML, err := yaml.Marshal(config)
+	require.NoError(t, err)
+	assert.Contains(t, string(rulesYAML), "      team: checkout\n")
+	assert.NoError(t, prometheus.ValidateRulesYAML(string(rulesYAML)))
+}
@@ -0,0 +1,189 @@
+package eventhandling
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	prometheus_model "github.com/prometheus/common/model"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// defaultErrorBudgetPeriod is the error budget period of burn rate alerts if none is configured
+const defaultErrorBudgetPeriod = prometheus_model.Duration(30 * 24 * time.Hour)
+
+// recordingRulePrefix is the prefix of the recording rules generated for burn rate alerts
+const recordingRulePrefix = "keptn_slo:"
+
+// invalidMetricNameChars matches the characters of an SLI name that are not allowed in a metric name
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// serviceLevelObjectives is the content of the slo.yaml including the extension of prometheus-service
+type serviceLevelObjectives struct {
+	Objectives []*sloObjective `yaml:"objectives"`
+}
+
+// sloObjective is an objective of the slo.yaml including the extension of prometheus-service
+type sloObjective struct {
+	keptnevents.SLO `yaml:",inline"`
+	// Prometheus configures the alerting rules generated for the objective
+	Prometheus *objectiveExtension `yaml:"prometheus,omitempty"`
+}
+
+// objectiveExtension contains the settings of prometheus-service for an objective
+type objectiveExtension struct {
+	// BurnRate replaces the threshold alert of the objective by a multi-window multi-burn-rate alert
+	BurnRate *burnRateSettings `yaml:"burnRate,omitempty"`
+	// Alert overrides the settings of prometheus/alerts.yaml for the alerting rule of the objective
+	Alert *alertSettings `yaml:"alert,omitempty"`
+}
+
+// burnRateSettings describes the error budget of an objective whose SLI returns the ratio of failed requests
+type burnRateSettings struct {
+	// Target is the percentage of successful requests, e.g. 99.9
+	Target float64 `yaml:"target"`
+	// Period is the period of the error budget (default: 30d)
+	Period prometheus_model.Duration `yaml:"period,omitempty"`
+	// Windows replaces the default windows
+	Windows []burnRateWindow `yaml:"windows,omitempty"`
+}
+
+// burnRateWindow alerts if the error budget is consumed with the burn rate factor in both the long and the short window
+type burnRateWindow struct {
+	Long   prometheus_model.Duration `yaml:"long"`
+	Short  prometheus_model.Duration `yaml:"short"`
+	Factor float64                   `yaml:"factor"`
+}
+
+// defaultBurnRateWindows contains the windows and the consumed error budget (in percent of the period) of the
+// alerts recommended by the Google SRE workbook
+var defaultBurnRateWindows = []struct {
+	long, short       time.Duration
+	budgetConsumption float64
+}{
+	{long: time.Hour, short: 5 * time.Minute, budgetConsumption: 2},
+	{long: 6 * time.Hour, short: 30 * time.Minute, budgetConsumption: 5},
+	{long: 24 * time.Hour, short: 2 * time.Hour, budgetConsumption: 10},
+	{long: 3 * 24 * time.Hour, short: 6 * time.Hour, budgetConsumption: 10},
+}
+
+// getWindows returns the configured windows or the default windows with burn rate factors scaled to the period
+func (s burnRateSettings) getWindows() []burnRateWindow {
+	if len(s.Windows) > 0 {
+		return s.Windows
+	}
+
+	period := s.Period
+	if period == 0 {
+		period = defaultErrorBudgetPeriod
+	}
+
+	var windows []burnRateWindow
+	for _, window := range defaultBurnRateWindows {
+		windows = append(windows, burnRateWindow{
+			Long:   prometheus_model.Duration(window.long),
+			Short:  prometheus_model.Duration(window.short),
+			Factor: window.budgetConsumption / 100 * float64(period) / float64(window.long),
+		})
+	}
+	return windows
+}
+
+func (s burnRateSettings) validate() error {
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("target %v is not between 0 and 100", s.Target)
+	}
+	for _, window := range s.Windows {
+		if window.Short <= 0 || window.Long <= window.Short {
+			return fmt.Errorf("long window %s must be longer than short window %s", window.Long, window.Short)
+		}
+		if window.Factor <= 0 {
+			return fmt.Errorf("burn rate factor of window %s must be positive", window.Long)
+		}
+	}
+	return nil
+}
+
+// getRecordingRuleNamePrefix returns the common prefix of the recording rules of the SLI
+func getRecordingRuleNamePrefix(sli string) string {
+	return recordingRulePrefix + invalidMetricNameChars.ReplaceAllString(sli, "_") + ":error_ratio_"
+}
+
+// getRecordingRuleName returns the name of the recording rule of the SLI evaluated over the window
+func getRecordingRuleName(sli string, window prometheus_model.Duration) string {
+	return getRecordingRuleNamePrefix(sli) + window.String()
+}
+
+// addBurnRateRules adds the recording rules of the windows of the SLI for the deployment type of the prometheus
+// handler to the alerting group and returns the expression of the burn rate alert
+func addBurnRateRules(
+	alertingGroupConfig *alertingGroup, prometheusHandler *prometheus.Handler, sli string, settings burnRateSettings, labels alertingLabel,
+) (string, error) {
+	if err := settings.validate(); err != nil {
+		return "", err
+	}
+	windows := settings.getWindows()
+
+	durations := map[prometheus_model.Duration]bool{}
+	for _, window := range windows {
+		durations[window.Long] = true
+		durations[window.Short] = true
+	}
+
+	var recordingRules []*alertingRule
+	for duration := range durations {
+		end := time.Now()
+		expr, err := prometheusHandler.GetMetricQuery(sli, end.Add(-time.Duration(duration)), end)
+		if err != nil {
+			return "", err
+		}
+
+		recordingLabels := alertingLabel{
+			Service:      labels.Service,
+			Project:      labels.Project,
+			Stage:        labels.Stage,
+			Deployment:   labels.Deployment,
+			KeptnManaged: labels.KeptnManaged,
+		}
+		recordingRules = append(recordingRules, &alertingRule{
+			Record: getRecordingRuleName(sli, duration),
+			Expr:   expr,
+			Labels: &recordingLabels,
+		})
+	}
+	sort.Slice(recordingRules, func(i, j int) bool {
+		return recordingRules[i].Record < recordingRules[j].Record
+	})
+
+	alertingGroupConfig.Rules = append(recordingRules, alertingGroupConfig.Rules...)
+
+	selector := fmt.Sprintf(`{project="%s",stage="%s",service="%s",deployment="%s"}`, labels.Project, labels.Stage, labels.Service, labels.Deployment)
+	errorBudget := (100 - settings.Target) / 100
+
+	var conditions []string
+	for _, window := range windows {
+		threshold := strconv.FormatFloat(window.Factor*errorBudget, 'g', 12, 64)
+		conditions = append(conditions, fmt.Sprintf("(%s%s > %s and %s%s > %s)",
+			getRecordingRuleName(sli, window.Long), selector, threshold,
+			getRecordingRuleName(sli, window.Short), selector, threshold,
+		))
+	}
+	return strings.Join(conditions, " or "), nil
+}
+
+// removeRecordingRules removes the recording rules whose name starts with the prefix
+func removeRecordingRules(alertingGroupConfig *alertingGroup, prefix string) {
+	var rules []*alertingRule
+	for _, rule := range alertingGroupConfig.Rules {
+		if rule.Record != "" && strings.HasPrefix(rule.Record, prefix) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	alertingGroupConfig.Rules = rules
+}
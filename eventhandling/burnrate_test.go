@@ -0,0 +1,101 @@
+package eventhandling
+
+import (
+	"testing"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	prometheus_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+const testBurnRateSLO = `spec_version: "1.0"
+objectives:
+  - sli: error_rate
+    pass:
+      - criteria:
+          - "<=0.01"
+    prometheus:
+      burnRate:
+        target: 99.9
+        period: 30d
+`
+
+func Test_retrieveSLOsWithBurnRate(t *testing.T) {
+	resourceHandler := fakeResourceHandler{resources: map[string]string{
+		"/v1/project/sockshop/stage/dev/service/carts/resource/slo.yaml": testBurnRateSLO,
+	}}
+
+	slos, err := retrieveSLOs(resourceHandler, "sockshop", "dev", "carts")
+	require.NoError(t, err)
+	require.Len(t, slos.Objectives, 1)
+	assert.Equal(t, "error_rate", slos.Objectives[0].SLI)
+	assert.Equal(t, []string{"<=0.01"}, slos.Objectives[0].Pass[0].Criteria)
+	require.NotNil(t, slos.Objectives[0].Prometheus)
+	assert.Equal(t, 99.9, slos.Objectives[0].Prometheus.BurnRate.Target)
+}
+
+func Test_burnRateSettingsGetWindows(t *testing.T) {
+	windows := burnRateSettings{Target: 99.9}.getWindows()
+
+	require.Len(t, windows, 4)
+	assert.Equal(t, prometheus_model.Duration(time.Hour), windows[0].Long)
+	assert.Equal(t, prometheus_model.Duration(5*time.Minute), windows[0].Short)
+	assert.InDelta(t, 14.4, windows[0].Factor, 1e-9)
+	assert.InDelta(t, 6, windows[1].Factor, 1e-9)
+	assert.InDelta(t, 3, windows[2].Factor, 1e-9)
+	assert.InDelta(t, 1, windows[3].Factor, 1e-9)
+
+	// the factors are scaled to the error budget period
+	windows = burnRateSettings{Target: 99.9, Period: prometheus_model.Duration(7 * 24 * time.Hour)}.getWindows()
+	assert.InDelta(t, 3.36, windows[0].Factor, 1e-9)
+}
+
+func Test_addBurnRateRules(t *testing.T) {
+	prometheusHandler := prometheus.NewPrometheusHandler("", &keptnv2.EventData{Project: "sockshop", Stage: "dev", Service: "carts"}, "primary", nil, nil)
+	labels := alertingLabel{Severity: "webhook", Service: "carts", Project: "sockshop", Stage: "dev", Deployment: "primary", KeptnManaged: "true"}
+	settings := burnRateSettings{
+		Target: 99,
+		Windows: []burnRateWindow{
+			{Long: prometheus_model.Duration(time.Hour), Short: prometheus_model.Duration(5 * time.Minute), Factor: 14.4},
+		},
+	}
+	group := &alertingGroup{Rules: []*alertingRule{
+		{Record: "keptn_slo:error_rate:error_ratio_6h"},
+		{Alert: "error_rate"},
+	}}
+
+	// the recording rules of a previous configuration are removed before the rules are recreated
+	removeRecordingRules(group, getRecordingRuleNamePrefix("error_rate"))
+	expr, err := addBurnRateRules(group, prometheusHandler, "error_rate", settings, labels)
+	require.NoError(t, err)
+
+	selector := `{project="sockshop",stage="dev",service="carts",deployment="primary"}`
+	assert.Equal(t, "(keptn_slo:error_rate:error_ratio_1h"+selector+" > 0.144 and keptn_slo:error_rate:error_ratio_5m"+selector+" > 0.144)", expr)
+
+	require.Len(t, group.Rules, 3)
+	assert.Equal(t, "keptn_slo:error_rate:error_ratio_1h", group.Rules[0].Record)
+	assert.Contains(t, group.Rules[0].Expr, "[3600s]")
+	assert.Equal(t, "", group.Rules[0].Labels.Severity)
+	assert.Equal(t, "carts", group.Rules[0].Labels.Service)
+	assert.Equal(t, "primary", group.Rules[0].Labels.Deployment)
+	assert.Equal(t, "keptn_slo:error_rate:error_ratio_5m", group.Rules[1].Record)
+	assert.Contains(t, group.Rules[1].Expr, "[300s]")
+	assert.Equal(t, "error_rate", group.Rules[2].Alert)
+}
+
+func Test_addBurnRateRulesInvalidSettings(t *testing.T) {
+	prometheusHandler := prometheus.NewPrometheusHandler("", &keptnv2.EventData{Project: "sockshop", Stage: "dev", Service: "carts"}, "primary", nil, nil)
+
+	_, err := addBurnRateRules(&alertingGroup{}, prometheusHandler, "error_rate", burnRateSettings{Target: 100}, alertingLabel{})
+	assert.Error(t, err)
+
+	_, err = addBurnRateRules(&alertingGroup{}, prometheusHandler, "error_rate", burnRateSettings{
+		Target:  99,
+		Windows: []burnRateWindow{{Long: prometheus_model.Duration(time.Minute), Short: prometheus_model.Duration(time.Hour), Factor: 1}},
+	}, alertingLabel{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package eventhandling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"gopkg.in/yaml.v2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// monitoringFilter selects the scrape jobs, alerting groups and Prometheus Operator objects generated for the
+// services of a project
+type monitoringFilter struct {
+	project string
+	// service restricts the filter to a single service if set
+	service string
+	// keepStages contains the stages whose entries are never selected
+	keepStages map[string]bool
+}
+
+// matches returns true if the entry generated for the given service and stage is selected
+func (f monitoringFilter) matches(service string, stage string) bool {
+	if f.service != "" && service != f.service {
+		return false
+	}
+	return !f.keepStages[stage]
+}
+
+// matchesEntry returns true if the entry generated for the given service in the namespace <project>-<stage> is
+// selected. The project and stage are taken from the labels of the entry. Entries generated by earlier versions do not
+// carry them, in this case the namespace is only attributed to the project if the rest of it is a stage without dash,
+// so that e.g. sockshop-eu-dev is not attributed to the project sockshop.
+func (f monitoringFilter) matchesEntry(service string, namespace string, project string, stage string) bool {
+	if project == "" {
+		if !strings.HasPrefix(namespace, f.project+"-") {
+			return false
+		}
+		project = f.project
+		stage = strings.TrimPrefix(namespace, f.project+"-")
+		if strings.Contains(stage, "-") {
+			return false
+		}
+	}
+
+	if project != f.project || namespace != project+"-"+stage {
+		return false
+	}
+	return f.matches(service, stage)
+}
+
+// labelSelector returns the label selector of the Prometheus Operator objects of the project (and service)
+func (f monitoringFilter) labelSelector() string {
+	selector := prometheus.ManagedLabel + "=true," + prometheus.ProjectLabel + "=" + f.project
+	if f.service != "" {
+		selector += "," + prometheus.ServiceLabel + "=" + f.service
+	}
+	return selector
+}
+
+// getRemovedStagesFilter returns a filter selecting the entries of the service in stages that are not part of the
+// shipyard
+func getRemovedStagesFilter(shipyard *keptnv2.Shipyard, eventData keptnevents.ConfigureMonitoringEventData) monitoringFilter {
+	filter := monitoringFilter{
+		project:    eventData.Project,
+		service:    eventData.Service,
+		keepStages: map[string]bool{},
+	}
+	for _, stage := range shipyard.Spec.Stages {
+		filter.keepStages[stage.Name] = true
+	}
+	return filter
+}
+
+// removeScrapeJobs removes the scrape jobs created by createScrapeJobConfig that are selected by the filter and
+// returns their names
+func removeScrapeJobs(config *prometheus.Config, filter monitoringFilter) []string {
+	var removed []string
+	var scrapeConfigs []*prometheus.ScrapeConfig
+
+	for _, scrapeConfig := range config.ScrapeConfigs {
+		service, namespace, _, ok := parseScrapeJob(scrapeConfig)
+		project, stage := getScrapeJobProjectAndStage(scrapeConfig)
+		if ok && filter.matchesEntry(service, namespace, project, stage) && isManagedScrapeConfig(scrapeConfig) {
+			removed = append(removed, scrapeConfig.JobName)
+			continue
+		}
+		scrapeConfigs = append(scrapeConfigs, scrapeConfig)
+	}
+
+	config.ScrapeConfigs = scrapeConfigs
+	return removed
+}
+
+// removeAlertingGroups removes the alerting groups created by createPrometheusAlertsIfSLOsAndRemediationDefined that
+// are selected by the filter and returns their names
+func removeAlertingGroups(alertingRulesConfig *alertingRules, filter monitoringFilter) []string {
+	var removed []string
+	var groups []*alertingGroup
+
+	for _, group := range alertingRulesConfig.Groups {
+		service, namespace, ok := parseAlertingGroupName(group.Name)
+		project, stage := getAlertingGroupProjectAndStage(group)
+		if ok && filter.matchesEntry(service, namespace, project, stage) && isManagedAlertingGroup(group) {
+			removed = append(removed, group.Name)
+			continue
+		}
+		groups = append(groups, group)
+	}
+
+	alertingRulesConfig.Groups = groups
+	return removed
+}
+
+// parseScrapeJob returns the service, the namespace and the deployment suffix (-canary, -primary or empty) of a scrape
+// job named <service>-<namespace>[-canary|-primary]
+func parseScrapeJob(scrapeConfig *prometheus.ScrapeConfig) (string, string, string, bool) {
+	namespace := getScrapeJobNamespace(scrapeConfig)
+	if namespace == "" {
+		return "", "", "", false
+	}
+
+	for _, suffix := range []string{"-canary", "-primary", ""} {
+		if service := strings.TrimSuffix(scrapeConfig.JobName, "-"+namespace+suffix); service != scrapeConfig.JobName && service != "" {
+			return service, namespace, suffix, true
+		}
+	}
+	return "", "", "", false
+}
+
+// parseAlertingGroupName returns the service and the namespace of an alerting group named <service> <namespace> alerts
+// or of a recording rule group named <service> <namespace> recordings
+func parseAlertingGroupName(name string) (string, string, bool) {
+	parts := strings.Split(name, " ")
+	if len(parts) != 3 || (parts[2] != "alerts" && parts[2] != "recordings") || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// getScrapeJobNamespace returns the namespace scraped by the job, which is either the only namespace of its
+// kubernetes_sd_configs or the namespace of its static target <k8s-service>.<namespace>:<port>
+func getScrapeJobNamespace(scrapeConfig *prometheus.ScrapeConfig) string {
+	for _, sdConfig := range scrapeConfig.KubernetesSDConfigs {
+		if sdConfig == nil {
+			continue
+		}
+
+		var names interface{}
+		switch namespaces := (*sdConfig)["namespaces"].(type) {
+		case map[string]interface{}:
+			names = namespaces["names"]
+		case map[interface{}]interface{}:
+			names = namespaces["names"]
+		}
+
+		switch names := names.(type) {
+		case []string:
+			if len(names) == 1 {
+				return names[0]
+			}
+		case []interface{}:
+			if len(names) == 1 {
+				if name, ok := names[0].(string); ok {
+					return name
+				}
+			}
+		}
+	}
+
+	for _, staticConfig := range scrapeConfig.StaticConfigs {
+		for _, target := range staticConfig.Targets {
+			host := strings.Split(target, ":")[0]
+			if i := strings.Index(host, "."); i >= 0 {
+				return host[i+1:]
+			}
+		}
+	}
+
+	return ""
+}
+
+// cleanupPrometheusConfigMap removes the scrape jobs and alerting groups selected by the filter from the Prometheus
+// configmap
+func cleanupPrometheusConfigMap(k sdk.IKeptn, filter monitoringFilter) error {
+	kubeAPI, err := utils.GetKubeClient()
+	if err != nil {
+		return err
+	}
+
+	// the configmap is read and modified again if it has been updated concurrently
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cmPrometheus, err := kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Get(context.TODO(), env.PrometheusConfigMap, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		config, err := prometheus.LoadYamlConfiguration(cmPrometheus.Data[env.PrometheusConfigFileName])
+		if err != nil {
+			return err
+		}
+
+		alertingRulesFileName := getAlertingRulesFileName(config, cmPrometheus.Data)
+		var alertingRulesConfig alertingRules
+		if cmPrometheus.Data[alertingRulesFileName] != "" {
+			if err := yaml.Unmarshal([]byte(cmPrometheus.Data[alertingRulesFileName]), &alertingRulesConfig); err != nil {
+				return fmt.Errorf("unable to parse altering rules configuration: %w", err)
+			}
+		}
+
+		removedJobs := removeScrapeJobs(config, filter)
+		removedGroups := removeAlertingGroups(&alertingRulesConfig, filter)
+		if len(removedJobs) == 0 && len(removedGroups) == 0 {
+			k.Logger().Infof("No scrape jobs or alerting rules of project %s to remove", filter.project)
+			return nil
+		}
+
+		// only the modified scrape configs and rule files are written, the rest of the document is preserved
+		updatedConfigYAMLString, err := prometheus.PatchYamlConfiguration(cmPrometheus.Data[env.PrometheusConfigFileName], config)
+		if err != nil {
+			return err
+		}
+		alertingRulesYAMLString, err := yaml.Marshal(alertingRulesConfig)
+		if err != nil {
+			return err
+		}
+
+		if err := validatePrometheusConfiguration(updatedConfigYAMLString, string(alertingRulesYAMLString)); err != nil {
+			return err
+		}
+
+		cmPrometheus.Data[env.PrometheusConfigFileName] = updatedConfigYAMLString
+		if len(removedGroups) > 0 {
+			cmPrometheus.Data[alertingRulesFileName] = string(alertingRulesYAMLString)
+		}
+
+		_, err = kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Update(context.TODO(), cmPrometheus, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+
+		k.Logger().Infof("Removed scrape jobs %v and alerting groups %v", removedJobs, removedGroups)
+		return nil
+	})
+}
+
+// cleanupPrometheusOperator deletes the ServiceMonitors, PodMonitors and PrometheusRules selected by the filter
+func cleanupPrometheusOperator(k sdk.IKeptn, operatorHelper *prometheus.OperatorHelper, filter monitoringFilter) error {
+	resources := []schema.GroupVersionResource{prometheus.ServiceMonitorResource, prometheus.PodMonitorResource, prometheus.PrometheusRuleResource}
+
+	for _, resource := range resources {
+		objects, err := operatorHelper.List(resource, env.PrometheusNamespace, filter.labelSelector())
+		if k8serrors.IsNotFound(err) {
+			// the CRD is not installed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objects {
+			objectLabels := obj.GetLabels()
+			if !filter.matches(objectLabels[prometheus.ServiceLabel], objectLabels[prometheus.StageLabel]) {
+				continue
+			}
+
+			if err := operatorHelper.Delete(resource, obj.GetNamespace(), obj.GetName()); err != nil {
+				return err
+			}
+			k.Logger().Infof("Removed %s %s/%s", resource.Resource, obj.GetNamespace(), obj.GetName())
+		}
+	}
+
+	return nil
+}
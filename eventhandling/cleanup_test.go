@@ -0,0 +1,163 @@
+package eventhandling
+
+import (
+	"testing"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+const cleanupPrometheusYAML = `
+scrape_configs:
+  - job_name: prometheus
+    static_configs:
+      - targets: ['localhost:9090']
+  - job_name: carts-sockshop-dev
+    static_configs:
+      - targets: ['carts.sockshop-dev:80']
+  - job_name: carts-sockshop-production-primary
+    kubernetes_sd_configs:
+      - role: endpoints
+        namespaces:
+          names: ['sockshop-production']
+    relabel_configs:
+      - target_label: keptn_managed
+        replacement: 'true'
+  - job_name: orders-sockshop-dev-canary
+    static_configs:
+      - targets: ['orders-canary.sockshop-dev:80']
+  - job_name: carts-other-dev
+    static_configs:
+      - targets: ['carts.other-dev:80']
+  - job_name: carts-sockshop-hardening
+    static_configs:
+      - targets: ['carts.sockshop-hardening:8080']
+`
+
+func getJobNames(config *prometheus.Config) []string {
+	var names []string
+	for _, scrapeConfig := range config.ScrapeConfigs {
+		names = append(names, scrapeConfig.JobName)
+	}
+	return names
+}
+
+func Test_removeScrapeJobsOfService(t *testing.T) {
+	config, err := prometheus.LoadYamlConfiguration(cleanupPrometheusYAML)
+	require.NoError(t, err)
+
+	removed := removeScrapeJobs(config, monitoringFilter{project: "sockshop", service: "carts"})
+
+	assert.Equal(t, []string{"carts-sockshop-dev", "carts-sockshop-production-primary"}, removed)
+	// the unmanaged job carts-sockshop-hardening is kept
+	assert.Equal(t, []string{"prometheus", "orders-sockshop-dev-canary", "carts-other-dev", "carts-sockshop-hardening"}, getJobNames(config))
+}
+
+func Test_removeScrapeJobsOfProject(t *testing.T) {
+	config, err := prometheus.LoadYamlConfiguration(cleanupPrometheusYAML)
+	require.NoError(t, err)
+
+	removed := removeScrapeJobs(config, monitoringFilter{project: "sockshop"})
+
+	assert.Equal(t, []string{"carts-sockshop-dev", "carts-sockshop-production-primary", "orders-sockshop-dev-canary"}, removed)
+	assert.Equal(t, []string{"prometheus", "carts-other-dev", "carts-sockshop-hardening"}, getJobNames(config))
+}
+
+func Test_removeScrapeJobsOfRemovedStages(t *testing.T) {
+	config := &prometheus.Config{}
+	for _, stage := range []string{"dev", "hardening", "production"} {
+		require.NoError(t, createScrapeJobConfig(config, "sockshop", stage, "carts", false, true, testScrapeSettings, scrapeTargetDiscoveryEndpoints))
+		require.NoError(t, createScrapeJobConfig(config, "sockshop", stage, "carts", true, false, testScrapeSettings, scrapeTargetDiscoveryStatic))
+	}
+
+	shipyard := &keptnv2.Shipyard{Spec: keptnv2.ShipyardSpec{Stages: []keptnv2.Stage{{Name: "dev"}, {Name: "production"}}}}
+	filter := getRemovedStagesFilter(shipyard, keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"})
+
+	removed := removeScrapeJobs(config, filter)
+
+	assert.Equal(t, []string{"carts-sockshop-hardening-primary", "carts-sockshop-hardening-canary"}, removed)
+	assert.Len(t, config.ScrapeConfigs, 4)
+}
+
+func Test_removeAlertingGroups(t *testing.T) {
+	alertingRulesConfig := alertingRules{
+		Groups: []*alertingGroup{
+			{Name: "carts sockshop-dev alerts"},
+			{Name: "carts sockshop-production alerts"},
+			{Name: "orders sockshop-dev alerts"},
+			{Name: "custom alerts"},
+		},
+	}
+
+	removed := removeAlertingGroups(&alertingRulesConfig, monitoringFilter{project: "sockshop", service: "carts", keepStages: map[string]bool{"dev": true}})
+
+	assert.Equal(t, []string{"carts sockshop-production alerts"}, removed)
+	require.Len(t, alertingRulesConfig.Groups, 3)
+	assert.Equal(t, "custom alerts", alertingRulesConfig.Groups[2].Name)
+}
+
+func Test_removeScrapeJobsOfOverlappingProjects(t *testing.T) {
+	config := &prometheus.Config{}
+	for _, project := range []string{"sockshop", "sockshop-eu"} {
+		for _, stage := range []string{"dev", "pre-prod"} {
+			require.NoError(t, createScrapeJobConfig(config, project, stage, "carts", false, false, testScrapeSettings, scrapeTargetDiscoveryStatic))
+			require.NoError(t, createScrapeJobConfig(config, project, stage, "carts", false, true, testScrapeSettings, scrapeTargetDiscoveryEndpoints))
+		}
+	}
+
+	removed := removeScrapeJobs(config, monitoringFilter{project: "sockshop"})
+	assert.Equal(t, []string{"carts-sockshop-dev", "carts-sockshop-dev-primary", "carts-sockshop-pre-prod", "carts-sockshop-pre-prod-primary"}, removed)
+
+	removed = removeScrapeJobs(config, monitoringFilter{project: "sockshop-eu", service: "carts", keepStages: map[string]bool{"dev": true}})
+	assert.Equal(t, []string{"carts-sockshop-eu-pre-prod", "carts-sockshop-eu-pre-prod-primary"}, removed)
+	assert.Equal(t, []string{"carts-sockshop-eu-dev", "carts-sockshop-eu-dev-primary"}, getJobNames(config))
+}
+
+func Test_removeScrapeJobsWithoutProjectLabels(t *testing.T) {
+	config, err := prometheus.LoadYamlConfiguration(`
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    static_configs:
+      - targets: ['carts.sockshop-dev:80']
+  - job_name: carts-sockshop-eu-dev
+    static_configs:
+      - targets: ['carts.sockshop-eu-dev:80']
+`)
+	require.NoError(t, err)
+
+	// jobs generated by earlier versions are only attributed to the project if the namespace cannot belong to another
+	// project with a dash in its name
+	removed := removeScrapeJobs(config, monitoringFilter{project: "sockshop"})
+	assert.Equal(t, []string{"carts-sockshop-dev"}, removed)
+	assert.Equal(t, []string{"carts-sockshop-eu-dev"}, getJobNames(config))
+}
+
+func Test_removeAlertingGroupsOfOverlappingProjects(t *testing.T) {
+	newGroup := func(name string, project string, stage string) *alertingGroup {
+		return &alertingGroup{Name: name, Rules: []*alertingRule{
+			{Alert: "response_time_p95", Labels: &alertingLabel{Service: "carts", Project: project, Stage: stage, KeptnManaged: "true"}},
+		}}
+	}
+	alertingRulesConfig := alertingRules{
+		Groups: []*alertingGroup{
+			newGroup("carts sockshop-dev alerts", "sockshop", "dev"),
+			newGroup("carts sockshop-eu-dev alerts", "sockshop-eu", "dev"),
+			newGroup("carts sockshop-eu-dev recordings", "sockshop-eu", "dev"),
+		},
+	}
+
+	// the stage cleanup of sockshop/carts does not remove the groups of sockshop-eu
+	shipyard := &keptnv2.Shipyard{Spec: keptnv2.ShipyardSpec{Stages: []keptnv2.Stage{{Name: "dev"}}}}
+	removed := removeAlertingGroups(&alertingRulesConfig, getRemovedStagesFilter(shipyard, keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}))
+	assert.Empty(t, removed)
+
+	removed = removeAlertingGroups(&alertingRulesConfig, monitoringFilter{project: "sockshop"})
+	assert.Equal(t, []string{"carts sockshop-dev alerts"}, removed)
+
+	removed = removeAlertingGroups(&alertingRulesConfig, monitoringFilter{project: "sockshop-eu"})
+	assert.Equal(t, []string{"carts sockshop-eu-dev alerts", "carts sockshop-eu-dev recordings"}, removed)
+}
@@ -0,0 +1,61 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_createScrapeJobConfigStatic(t *testing.T) {
+	config := &prometheus.Config{}
+
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "production", "carts", true, false, testScrapeSettings, scrapeTargetDiscoveryStatic))
+
+	require.Len(t, config.ScrapeConfigs, 1)
+	scrapeConfig := config.ScrapeConfigs[0]
+	assert.Equal(t, "carts-sockshop-production-canary", scrapeConfig.JobName)
+	assert.Equal(t, []string{"carts-canary.sockshop-production:80"}, scrapeConfig.StaticConfigs[0].Targets)
+	assert.Nil(t, scrapeConfig.KubernetesSDConfigs)
+}
+
+func Test_createScrapeJobConfigServiceDiscovery(t *testing.T) {
+	config := &prometheus.Config{}
+
+	// an existing static job is converted to service discovery
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "production", "carts", false, true, testScrapeSettings, scrapeTargetDiscoveryStatic))
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "production", "carts", false, true, testScrapeSettings, scrapeTargetDiscoveryEndpoints))
+
+	require.Len(t, config.ScrapeConfigs, 1)
+	scrapeConfig := config.ScrapeConfigs[0]
+	assert.Equal(t, "carts-sockshop-production-primary", scrapeConfig.JobName)
+	assert.Nil(t, scrapeConfig.StaticConfigs)
+	require.Len(t, scrapeConfig.KubernetesSDConfigs, 1)
+	assert.Equal(t, "endpoints", (*scrapeConfig.KubernetesSDConfigs[0])["role"])
+	assert.Equal(t, map[string]interface{}{"names": []string{"sockshop-production"}}, (*scrapeConfig.KubernetesSDConfigs[0])["namespaces"])
+
+	require.NotEmpty(t, scrapeConfig.RelabelConfigs)
+	assert.Equal(t, prometheus.UntypedElement{
+		"source_labels": []string{"__meta_kubernetes_service_name"},
+		"regex":         "carts-primary",
+		"action":        "keep",
+	}, *scrapeConfig.RelabelConfigs[0])
+}
+
+func Test_createScrapeJobConfigPodDiscovery(t *testing.T) {
+	t.Setenv(scrapePodServiceLabelEnvName, "app.kubernetes.io/name")
+	config := &prometheus.Config{}
+
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, false, testScrapeSettings, scrapeTargetDiscoveryPod))
+
+	require.Len(t, config.ScrapeConfigs, 1)
+	scrapeConfig := config.ScrapeConfigs[0]
+	assert.Equal(t, "carts-sockshop-dev", scrapeConfig.JobName)
+	assert.Equal(t, "pod", (*scrapeConfig.KubernetesSDConfigs[0])["role"])
+	assert.Equal(t, prometheus.UntypedElement{
+		"source_labels": []string{"__meta_kubernetes_pod_label_app_kubernetes_io_name"},
+		"regex":         "carts",
+		"action":        "keep",
+	}, *scrapeConfig.RelabelConfigs[0])
+}
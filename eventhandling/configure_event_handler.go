@@ -8,12 +8,16 @@ import (
 	"github.com/keptn/go-utils/pkg/sdk"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 
 	configutils "github.com/keptn/go-utils/pkg/api/utils"
 	keptnevents "github.com/keptn/go-utils/pkg/lib"
@@ -23,10 +27,26 @@ import (
 	"github.com/keptn-contrib/prometheus-service/utils"
 	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
 	api "github.com/keptn/go-utils/pkg/api/utils"
-	prometheus_model "github.com/prometheus/common/model"
 )
 
 const metricsScrapePathEnvName = "METRICS_SCRAPE_PATH"
+const scrapeTargetDiscoveryEnvName = "SCRAPE_TARGET_DISCOVERY"
+const scrapePodServiceLabelEnvName = "SCRAPE_POD_SERVICE_LABEL"
+
+// scrapeTargetDiscoveryStatic scrapes the kubernetes service of a deployment via a static_configs target
+const scrapeTargetDiscoveryStatic = "static"
+
+// scrapeTargetDiscoveryEndpoints scrapes every endpoint of the kubernetes service via kubernetes_sd_configs
+const scrapeTargetDiscoveryEndpoints = "endpoints"
+
+// scrapeTargetDiscoveryPod scrapes every pod carrying the service label via kubernetes_sd_configs
+const scrapeTargetDiscoveryPod = "pod"
+
+// invalidLabelCharRegex matches all characters that kubernetes_sd_configs replaces in the names of meta labels
+var invalidLabelCharRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// prometheusConfigMutex serializes the modifications of the Prometheus configuration by concurrently handled events
+var prometheusConfigMutex sync.Mutex
 
 // ConfigureMonitoringEventHandler is responsible for processing configure monitoring events
 type ConfigureMonitoringEventHandler struct {
@@ -47,25 +67,32 @@ type alertingGroup struct {
 }
 
 type alertingRule struct {
-	Alert       string               `json:"alert" yaml:"alert"`
+	// Record is the name of a recording rule, which is set instead of Alert
+	Record      string               `json:"record,omitempty" yaml:"record,omitempty"`
+	Alert       string               `json:"alert,omitempty" yaml:"alert,omitempty"`
 	Expr        string               `json:"expr" yaml:"expr"`
-	For         string               `json:"for" yaml:"for"`
+	For         string               `json:"for,omitempty" yaml:"for,omitempty"`
 	Labels      *alertingLabel       `json:"labels" yaml:"labels"`
-	Annotations *alertingAnnotations `json:"annotations" yaml:"annotations"`
+	Annotations *alertingAnnotations `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 }
 
 type alertingLabel struct {
-	Severity   string `json:"severity" yaml:"severity"`
-	PodName    string `json:"pod_name,omitempty" yaml:"pod_name"`
+	Severity   string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	PodName    string `json:"pod_name,omitempty" yaml:"pod_name,omitempty"`
 	Service    string `json:"service,omitempty" yaml:"service"`
 	Stage      string `json:"stage,omitempty" yaml:"stage"`
 	Project    string `json:"project,omitempty" yaml:"project"`
-	Deployment string `json:"deployment,omitempty" yaml:"deployment"`
+	Deployment string `json:"deployment,omitempty" yaml:"deployment,omitempty"`
+	// KeptnManaged marks the rules generated by prometheus-service
+	KeptnManaged string `json:"keptn_managed,omitempty" yaml:"keptn_managed,omitempty"`
+	// Extra contains the additional labels configured for the alerting rule
+	Extra map[string]string `json:"-" yaml:",inline"`
 }
 
 type alertingAnnotations struct {
 	Summary     string `json:"summary" yaml:"summary"`
 	Description string `json:"description" yaml:"descriptions"`
+	RunbookURL  string `json:"runbook_url,omitempty" yaml:"runbook_url,omitempty"`
 }
 
 // Execute processes an event
@@ -86,13 +113,31 @@ func (eh ConfigureMonitoringEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnE
 		return nil, err
 	}
 
-	err := eh.configurePrometheusAndStoreResources(k, eventData, os.Getenv("K8S_NAMESPACE"))
+	dryRun := isDryRun(event)
+
+	prometheusConfigMutex.Lock()
+	configResult, err := eh.configurePrometheusAndStoreResources(k, eventData, os.Getenv("K8S_NAMESPACE"), dryRun)
+	prometheusConfigMutex.Unlock()
 	if err != nil {
 		k.Logger().Error(err.Error())
 		return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "configure prometheus failed with error: " + err.Error()}
 	}
+	// waiting for the reloaded configuration does not block other events modifying the configuration
+	configResult.reload()
 
-	finishedEventData := eh.getConfigureMonitoringFinishedEvent(keptnv2.StatusSucceeded, keptnv2.ResultPass, *eventData, "Prometheus successfully configured and rule created")
+	message := "Prometheus successfully configured and rule created"
+	if len(configResult.messages) > 0 {
+		message += "; " + strings.Join(configResult.messages, "; ")
+	}
+	if dryRun {
+		message = getDryRunMessage(configResult.diffs)
+	}
+	result := keptnv2.ResultPass
+	if configResult.unverified {
+		result = keptnv2.ResultWarning
+	}
+
+	finishedEventData := eh.getConfigureMonitoringFinishedEvent(keptnv2.StatusSucceeded, result, *eventData, message)
 	k.Logger().Infof("Sending configure-monitoring.finished event with context: %s", event.Shkeptncontext)
 	if err := eh.sendConfigureMonitoringFinishedEvent(k, event, finishedEventData); err != nil {
 		k.Logger().Infof("Error while sending configure-monitoring.finished event: %s", err.Message)
@@ -102,27 +147,100 @@ func (eh ConfigureMonitoringEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnE
 	return finishedEventData, nil
 }
 
-// configurePrometheusAndStoreResources
-func (eh ConfigureMonitoringEventHandler) configurePrometheusAndStoreResources(k sdk.IKeptn, eventData *keptnevents.ConfigureMonitoringEventData, k8sNamespace string) error {
+// configurePrometheusAndStoreResources configures the scrape jobs, alerting rules and the Alertmanager, in a dry-run
+// only the diffs of the configuration files are added to the result
+func (eh ConfigureMonitoringEventHandler) configurePrometheusAndStoreResources(k sdk.IKeptn, eventData *keptnevents.ConfigureMonitoringEventData, k8sNamespace string, dryRun bool) (configurationResult, error) {
+	result := configurationResult{}
+
+	mode, operatorHelper, err := getPrometheusConfigMode(k)
+	if err != nil {
+		return result, err
+	}
+
+	if mode == prometheusConfigModeGit || storeGeneratedConfigEnabled() {
+		if utils.EnvVarOrDefault("CREATE_TARGETS", "true") == "true" {
+			k.Logger().Debug("Storing generated Prometheus configuration in the configuration repository")
+			diffs, err := eh.storeGeneratedConfig(k, k.APIV1().ResourcesV1(), *eventData, dryRun)
+			if err != nil {
+				return result, err
+			}
+			result.diffs = append(result.diffs, diffs...)
+		}
+
+		if mode == prometheusConfigModeGit {
+			return result, nil
+		}
+	}
+
+	if mode == prometheusConfigModeOperator {
+		if dryRun {
+			return result, errors.New("dry-run is not supported with the Prometheus Operator")
+		}
+		if utils.EnvVarOrDefault("CREATE_TARGETS", "true") == "true" {
+			k.Logger().Debug("Configure prometheus monitoring with keptn using the Prometheus Operator")
+			if err := eh.configurePrometheusOperator(k, operatorHelper, *eventData); err != nil {
+				return result, err
+			}
+		}
+
+		if utils.EnvVarOrDefault("CREATE_ALERTS", "true") == "true" {
+			alertManagerMode, alertManagerOperatorHelper, err := getAlertManagerConfigMode(k, operatorHelper)
+			if err != nil {
+				return result, err
+			}
+			if alertManagerMode != prometheusConfigModeOperator {
+				k.Logger().Info("AlertmanagerConfig CRD is not installed, skipping the Alertmanager configuration")
+				return result, nil
+			}
+			if err := configureAlertmanagerConfig(k, alertManagerOperatorHelper, k8sNamespace); err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+
 	// (1) check if prometheus is installed
 	if eh.isPrometheusInstalled(k) {
 		if utils.EnvVarOrDefault("CREATE_TARGETS", "true") == "true" {
 			k.Logger().Debug("Configure prometheus monitoring with keptn")
-			if err := eh.updatePrometheusConfigMap(k, *eventData); err != nil {
-				return err
+			diff, err := eh.updatePrometheusConfigMap(k, *eventData, dryRun)
+			if err != nil {
+				return result, err
+			}
+			result.addDiff(diff)
+
+			if reloadConfigEnabled() && !dryRun {
+				result.addReload("Prometheus", func() error { return reloadPrometheus(k, *eventData) })
 			}
 		}
 
 		if utils.EnvVarOrDefault("CREATE_ALERTS", "true") == "true" {
+			alertManagerMode, alertManagerOperatorHelper, err := getAlertManagerConfigMode(k, nil)
+			if err != nil {
+				return result, err
+			}
+			if alertManagerMode == prometheusConfigModeOperator {
+				if dryRun {
+					return result, errors.New("dry-run is not supported with the AlertmanagerConfig of the Prometheus Operator")
+				}
+				// the Prometheus Operator reloads the Alertmanager itself
+				return result, configureAlertmanagerConfig(k, alertManagerOperatorHelper, k8sNamespace)
+			}
+
 			k.Logger().Debug("Configure prometheus alert manager with keptn")
-			err := eh.configurePrometheusAlertManager(k, k8sNamespace)
+			diff, err := eh.configurePrometheusAlertManager(k, k8sNamespace, dryRun)
 			if err != nil {
-				return err
+				return result, err
+			}
+			result.addDiff(diff)
+
+			if reloadConfigEnabled() && !dryRun {
+				result.addReload("Alertmanager", func() error { return reloadAlertManager(k) })
 			}
 		}
 	}
 
-	return nil
+	return result, nil
 }
 
 func (eh ConfigureMonitoringEventHandler) isPrometheusInstalled(k sdk.IKeptn) bool {
@@ -157,115 +275,215 @@ func getPrometheusAlertManagerServiceFromK8s() (*v1.ServiceList, error) {
 	return svcList, err
 }
 
-func (eh ConfigureMonitoringEventHandler) configurePrometheusAlertManager(k sdk.IKeptn, namespace string) error {
+// configurePrometheusAlertManager adds the keptn_integration receiver to the Alertmanager configmap, in a dry-run only
+// the diff of the Alertmanager configuration is returned
+func (eh ConfigureMonitoringEventHandler) configurePrometheusAlertManager(k sdk.IKeptn, namespace string, dryRun bool) (string, error) {
 	k.Logger().Info("Configuring Prometheus AlertManager...")
 	prometheusHelper, err := prometheus.NewPrometheusHelper(namespace)
+	if err != nil {
+		return "", err
+	}
+	integration, err := getAlertManagerIntegration(namespace)
+	if err != nil {
+		return "", err
+	}
+	prometheusHelper.Integration = &integration
+
+	if dryRun {
+		return prometheusHelper.DiffAMConfigMap(env.AlertManagerConfigMap, env.AlertManagerConfigFileName, env.AlertManagerNamespace)
+	}
 
 	k.Logger().Info("Updating Prometheus AlertManager configmap...")
 	err = prometheusHelper.UpdateAMConfigMap(env.AlertManagerConfigMap, env.AlertManagerConfigFileName, env.AlertManagerNamespace)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	k.Logger().Info("Prometheus AlertManager configuration successfully")
 
-	return nil
+	return "", nil
 }
 
-// updatePrometheusConfigMap updates the prometheus configmap with scrape configs and alerting rules
-func (eh ConfigureMonitoringEventHandler) updatePrometheusConfigMap(k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData) error {
+// updatePrometheusConfigMap updates the prometheus configmap with scrape configs and alerting rules, in a dry-run the
+// configmap is not updated and the diff of the prometheus.yml and the alerting rules is returned instead
+func (eh ConfigureMonitoringEventHandler) updatePrometheusConfigMap(k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData, dryRun bool) (string, error) {
 	scope := api.NewResourceScope()
 	scope.Project(eventData.Project)
 	scope.Resource("shipyard.yaml")
 
 	shipyard, err := GetShipyard(k.GetResourceHandler(), *scope)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	kubeAPI, err := utils.GetKubeClient()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	cmPrometheus, err := kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Get(context.TODO(), env.PrometheusConfigMap, metav1.GetOptions{})
+	stageScrapeSettings, err := getStageScrapeSettings(k, shipyard, eventData.Project, eventData.Service)
 	if err != nil {
-		// Print better error message when role binding is missing
-		g := glob.MustCompile("configmaps * is forbidden: User * cannot get resource * in API group * in the namespace *")
-		if g.Match(err.Error()) {
-			return errors.New("not enough permissions to access configmap. Check if the role binding is correct")
-		}
-		return err
+		return "", err
 	}
-	config, err := prometheus.LoadYamlConfiguration(cmPrometheus.Data[env.PrometheusConfigFileName])
-	if err != nil {
-		return err
-	}
-
-	scrapeIntervalString := utils.EnvVarOrDefault("SCRAPE_INTERVAL", "5s")
-	scrapeInterval, err := time.ParseDuration(scrapeIntervalString)
 
+	targetDiscovery, err := getScrapeTargetDiscovery()
 	if err != nil {
-		k.Logger().Error("Error while converting SCRAPE_INTERVAL value. Using default value instead!")
-		scrapeInterval = 5 * time.Second
+		return "", err
 	}
 
-	// check if alerting rules are already available
-	var alertingRulesConfig alertingRules
-	if cmPrometheus.Data["prometheus.rules"] != "" {
-		// take existing alerting rule
-		err := yaml.Unmarshal([]byte(cmPrometheus.Data["prometheus.rules"]), &alertingRulesConfig)
+	// the configmap is read and modified again if it has been updated concurrently
+	var diff string
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cmPrometheus, err := kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Get(context.TODO(), env.PrometheusConfigMap, metav1.GetOptions{})
 		if err != nil {
-			return fmt.Errorf("unable to parse altering rules configuration: %w", err)
+			// Print better error message when role binding is missing
+			g := glob.MustCompile("configmaps * is forbidden: User * cannot get resource * in API group * in the namespace *")
+			if g.Match(err.Error()) {
+				return errors.New("not enough permissions to access configmap. Check if the role binding is correct")
+			}
+			return err
+		}
+		config, err := prometheus.LoadYamlConfiguration(cmPrometheus.Data[env.PrometheusConfigFileName])
+		if err != nil {
+			return err
 		}
-	} else {
-		// create new empty alerting rule
-		alertingRulesConfig = alertingRules{}
-	}
-	// update: Create scrape job and alerting rules for each stage of the shipyard file
-	for _, stage := range shipyard.Spec.Stages {
-		var scrapeConfig *prometheus.ScrapeConfig
-		// (a) if a scrape config with the same name is available, update that one
 
-		// <service>-primary.<project>-<stage>
-		createScrapeJobConfig(scrapeConfig, config, eventData.Project, stage.Name, eventData.Service, false, true, scrapeInterval)
-		// <service>-canary.<project>-<stage>
-		createScrapeJobConfig(scrapeConfig, config, eventData.Project, stage.Name, eventData.Service, true, false, scrapeInterval)
-		// <service>.<project>-<stage>
-		createScrapeJobConfig(scrapeConfig, config, eventData.Project, stage.Name, eventData.Service, false, false, scrapeInterval)
+		// check if alerting rules are already available in the rule file loaded by Prometheus
+		alertingRulesFileName := getAlertingRulesFileName(config, cmPrometheus.Data)
+		var alertingRulesConfig alertingRules
+		if cmPrometheus.Data[alertingRulesFileName] != "" {
+			// take existing alerting rule
+			err := yaml.Unmarshal([]byte(cmPrometheus.Data[alertingRulesFileName]), &alertingRulesConfig)
+			if err != nil {
+				return fmt.Errorf("unable to parse altering rules configuration: %w", err)
+			}
+		} else {
+			// create new empty alerting rule
+			alertingRulesConfig = alertingRules{}
+		}
+		// update: Create scrape job and alerting rules for each stage of the shipyard file
+		for _, stage := range shipyard.Spec.Stages {
+			// <service>-primary.<project>-<stage>
+			if err := createScrapeJobConfig(config, eventData.Project, stage.Name, eventData.Service, false, true, stageScrapeSettings[stage.Name], targetDiscovery); err != nil {
+				return err
+			}
+			// <service>-canary.<project>-<stage>
+			if err := createScrapeJobConfig(config, eventData.Project, stage.Name, eventData.Service, true, false, stageScrapeSettings[stage.Name], targetDiscovery); err != nil {
+				return err
+			}
+			// <service>.<project>-<stage>
+			if err := createScrapeJobConfig(config, eventData.Project, stage.Name, eventData.Service, false, false, stageScrapeSettings[stage.Name], targetDiscovery); err != nil {
+				return err
+			}
 
-		alertingRulesConfig, err = eh.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, stage,
-			alertingRulesConfig)
+			alertingRulesConfig, err = eh.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, stage,
+				alertingRulesConfig)
 
+			if err != nil {
+				return fmt.Errorf("error configuring prometheus alerts: %w", err)
+			}
+
+			alertingRulesConfig, err = createSLIRecordingRules(k, eventData, stage, alertingRulesConfig)
+			if err != nil {
+				return fmt.Errorf("error configuring SLI recording rules: %w", err)
+			}
+		}
+
+		// remove the scrape jobs and alerting rules of stages that are no longer part of the shipyard
+		filter := getRemovedStagesFilter(shipyard, eventData)
+		removedJobs := removeScrapeJobs(config, filter)
+		removedGroups := removeAlertingGroups(&alertingRulesConfig, filter)
+		if len(removedJobs) > 0 || len(removedGroups) > 0 {
+			k.Logger().Infof("Removing scrape jobs %v and alerting groups %v of stages that are no longer part of the shipyard", removedJobs, removedGroups)
+		}
+
+		if len(alertingRulesConfig.Groups) > 0 && ensureRuleFileLoaded(config, alertingRulesFileName) {
+			k.Logger().Infof("Adding %s to the rule_files of the Prometheus configuration", alertingRulesFileName)
+		}
+
+		alertingRulesYAMLString, err := yaml.Marshal(alertingRulesConfig)
 		if err != nil {
-			return fmt.Errorf("error configuring prometheus alerts: %w", err)
+			return err
+		}
+
+		// only the modified scrape configs and rule files are written, the rest of the document is preserved
+		updatedConfigYAMLString, err := prometheus.PatchYamlConfiguration(cmPrometheus.Data[env.PrometheusConfigFileName], config)
+		if err != nil {
+			return err
+		}
+
+		if err := validatePrometheusConfiguration(updatedConfigYAMLString, string(alertingRulesYAMLString)); err != nil {
+			return err
 		}
+
+		if dryRun {
+			diff, err = diffPrometheusConfigMap(cmPrometheus.Data, updatedConfigYAMLString, alertingRulesFileName, string(alertingRulesYAMLString))
+			return err
+		}
+
+		// apply
+		cmPrometheus.Data[alertingRulesFileName] = string(alertingRulesYAMLString)
+		cmPrometheus.Data[env.PrometheusConfigFileName] = updatedConfigYAMLString
+		_, err = kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Update(context.TODO(), cmPrometheus, metav1.UpdateOptions{})
+		return err
+	})
+	return diff, err
+}
+
+// diffPrometheusConfigMap returns the unified diffs between the prometheus.yml and alerting rules of the configmap and
+// their updated content
+func diffPrometheusConfigMap(data map[string]string, updatedConfigYAML string, alertingRulesFileName string, alertingRulesYAML string) (string, error) {
+	configDiff, err := prometheus.UnifiedDiff(env.PrometheusConfigFileName, data[env.PrometheusConfigFileName], updatedConfigYAML)
+	if err != nil {
+		return "", err
 	}
-	alertingRulesYAMLString, err := yaml.Marshal(alertingRulesConfig)
+	alertingRulesDiff, err := prometheus.UnifiedDiff(alertingRulesFileName, data[alertingRulesFileName], alertingRulesYAML)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return configDiff + alertingRulesDiff, nil
+}
 
-	updatedConfigYAMLString, err := yaml.Marshal(config)
-	if err != nil {
-		return err
+// validatePrometheusConfiguration validates the prometheus.yml and the alerting rules before they are written, since
+// Prometheus keeps running with the previous configuration if the new one is rejected
+func validatePrometheusConfiguration(configYAML string, alertingRulesYAML string) error {
+	if err := prometheus.ValidateConfigYAML(configYAML); err != nil {
+		return fmt.Errorf("generated Prometheus configuration is invalid: %w", err)
 	}
+	if err := prometheus.ValidateRulesYAML(alertingRulesYAML); err != nil {
+		return fmt.Errorf("generated alerting rules are invalid: %w", err)
+	}
+	return nil
+}
+
+// getScrapeInterval returns the scrape interval configured by the SCRAPE_INTERVAL env var
+func getScrapeInterval(k sdk.IKeptn) time.Duration {
+	scrapeIntervalString := utils.EnvVarOrDefault("SCRAPE_INTERVAL", "5s")
+	scrapeInterval, err := time.ParseDuration(scrapeIntervalString)
 
-	// apply
-	cmPrometheus.Data["alerting_rules.yml"] = string(alertingRulesYAMLString)
-	cmPrometheus.Data[env.PrometheusConfigFileName] = string(updatedConfigYAMLString)
-	_, err = kubeAPI.CoreV1().ConfigMaps(env.PrometheusNamespace).Update(context.TODO(), cmPrometheus, metav1.UpdateOptions{})
 	if err != nil {
-		return err
+		k.Logger().Error("Error while converting SCRAPE_INTERVAL value. Using default value instead!")
+		scrapeInterval = 5 * time.Second
+	}
+	return scrapeInterval
+}
+
+// getScrapeTargetDiscovery returns the scrape target discovery configured by the SCRAPE_TARGET_DISCOVERY env var
+func getScrapeTargetDiscovery() (string, error) {
+	targetDiscovery := utils.EnvVarOrDefault(scrapeTargetDiscoveryEnvName, scrapeTargetDiscoveryStatic)
+	switch targetDiscovery {
+	case scrapeTargetDiscoveryStatic, scrapeTargetDiscoveryEndpoints, scrapeTargetDiscoveryPod:
+		return targetDiscovery, nil
+	default:
+		return "", fmt.Errorf("invalid value %s for %s", targetDiscovery, scrapeTargetDiscoveryEnvName)
 	}
-	return nil
 }
 
 func (eh ConfigureMonitoringEventHandler) createPrometheusAlertsIfSLOsAndRemediationDefined(
 	k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData, stage keptnv2.Stage, alertingRulesConfig alertingRules,
 ) (alertingRules, error) {
 	// fetch SLOs for the given service and stage
-	slos, err := retrieveSLOs(k.GetResourceHandler(), eventData, stage.Name)
+	slos, err := retrieveSLOs(k.GetResourceHandler(), eventData.Project, stage.Name, eventData.Service)
 	if err != nil || slos == nil {
 		k.Logger().Info("No SLO file found for stage " + stage.Name + ". No alerting rules created for this stage")
 		return alertingRulesConfig, nil
@@ -297,6 +515,9 @@ func (eh ConfigureMonitoringEventHandler) createPrometheusAlertsIfSLOsAndRemedia
 	var alertingGroupConfig *alertingGroup
 	alertingGroupName := eventData.Service + " " + eventData.Project + "-" + stage.Name + " alerts"
 	alertingGroupConfig = getAlertingGroup(&alertingRulesConfig, alertingGroupName)
+	if alertingGroupConfig != nil && !isManagedAlertingGroup(alertingGroupConfig) && !overwriteUnmanagedConfig() {
+		return alertingRulesConfig, fmt.Errorf("alerting group %s has not been created by %s and is not overwritten, set %s to true to overwrite it", alertingGroupName, utils.ServiceName, overwriteUnmanagedConfigEnvName)
+	}
 	if alertingGroupConfig == nil {
 		alertingGroupConfig = &alertingGroup{
 			Name: alertingGroupName,
@@ -304,20 +525,6 @@ func (eh ConfigureMonitoringEventHandler) createPrometheusAlertsIfSLOsAndRemedia
 		alertingRulesConfig.Groups = append(alertingRulesConfig.Groups, alertingGroupConfig)
 	}
 
-	// create a new prometheus handler in order to query SLI expressions
-	const deploymentType = "primary" // only create alerts for primary deployments
-	prometheusHandler := prometheus.NewPrometheusHandler(
-		"",
-		&keptnv2.EventData{
-			Project: eventData.Project,
-			Service: eventData.Service,
-			Stage:   stage.Name,
-		},
-		deploymentType,
-		nil,
-		nil,
-	)
-
 	// get SLI queries
 	projectCustomQueries, err := getCustomQueries(k.GetResourceHandler(), eventData.Project, stage.Name, eventData.Service)
 	if err != nil {
@@ -326,89 +533,161 @@ func (eh ConfigureMonitoringEventHandler) createPrometheusAlertsIfSLOsAndRemedia
 		return alertingRulesConfig, err
 	}
 
-	if projectCustomQueries != nil {
-		prometheusHandler.CustomQueries = projectCustomQueries
+	baselineOffset := getAlertBaselineOffset(k)
+
+	objectiveAlertSettings, err := getAlertSettings(k.GetResourceHandler(), eventData.Project, stage.Name, eventData.Service)
+	if err != nil {
+		return alertingRulesConfig, err
 	}
 
+	// create alerts for the workloads serving the traffic according to the deployment strategies of the stage
+	deploymentTypes := getDeploymentTypes(stage)
+	k.Logger().Infof("Creating alerts for deployment types %v of stage %s", deploymentTypes, stage.Name)
+
 	k.Logger().Info("Going over SLO.objectives")
 
 	for _, objective := range slos.Objectives {
 		k.Logger().Info("SLO:" + objective.DisplayName + ", " + objective.SLI)
-		// Get Prometheus Metric Expression
-		end := time.Now()
-		start := end.Add(-180 * time.Second)
 
-		expr, err := prometheusHandler.GetMetricQuery(objective.SLI, start, end)
-		if err != nil || expr == "" {
-			k.Logger().Error("No query defined for SLI " + objective.SLI + " in project " + eventData.Project)
-			continue
+		settings := objectiveAlertSettings[objective.SLI]
+		if objective.Prometheus != nil && objective.Prometheus.Alert != nil {
+			settings = settings.merge(*objective.Prometheus.Alert)
+			if err := settings.validate(); err != nil {
+				k.Logger().Errorf("Invalid alert settings of SLI %s in slo.yaml: %v", objective.SLI, err)
+				continue
+			}
 		}
-		k.Logger().Info("expr=" + expr)
-
-		if objective.Pass != nil {
-			for _, criteriaGroup := range objective.Pass {
-				for _, criteria := range criteriaGroup.Criteria {
-					if strings.Contains(criteria, "+") || strings.Contains(criteria, "-") || strings.Contains(
-						criteria, "%",
-					) || (!strings.Contains(criteria, "<") && !strings.Contains(criteria, ">")) {
-						continue
-					}
-					criteriaString := strings.Replace(criteria, "=", "", -1)
-					if strings.Contains(criteriaString, "<") {
-						criteriaString = strings.Replace(criteriaString, "<", ">", -1)
-					} else {
-						criteriaString = strings.Replace(criteriaString, ">", "<", -1)
-					}
-
-					// sanitize criteria string: remove whitespaces
-					criteriaString = strings.Replace(criteriaString, " ", "", -1)
-
-					var newAlertingRule *alertingRule
-					ruleName := objective.SLI
-					newAlertingRule = getAlertingRuleOfGroup(alertingGroupConfig, ruleName)
-					if newAlertingRule == nil {
-						newAlertingRule = &alertingRule{
-							Alert: ruleName,
-						}
-						alertingGroupConfig.Rules = append(alertingGroupConfig.Rules, newAlertingRule)
-					}
-					newAlertingRule.Alert = ruleName
-					newAlertingRule.Expr = expr + criteriaString
-					newAlertingRule.For = "10m" // TODO: introduce alert duration concept in SLO?
-					newAlertingRule.Labels = &alertingLabel{
-						Severity:   "webhook",
-						PodName:    fmt.Sprintf("%s-%s", eventData.Service, deploymentType),
-						Service:    eventData.Service,
-						Project:    eventData.Project,
-						Stage:      stage.Name,
-						Deployment: deploymentType,
-					}
-					newAlertingRule.Annotations = &alertingAnnotations{
-						Summary:     ruleName,
-						Description: "Pod name {{ $labels.pod_name }}",
-					}
-				}
+
+		// the recording rules of burn rate alerts are recreated and the alerting rules of deployment types that are no
+		// longer deployed are removed
+		removeRecordingRules(alertingGroupConfig, getRecordingRuleNamePrefix(objective.SLI))
+		removeAlertingRules(alertingGroupConfig, objective.SLI, deploymentTypes)
+
+		for _, deploymentType := range deploymentTypes {
+			// create a new prometheus handler in order to query SLI expressions
+			prometheusHandler := prometheus.NewPrometheusHandler(
+				"",
+				&keptnv2.EventData{
+					Project: eventData.Project,
+					Service: eventData.Service,
+					Stage:   stage.Name,
+				},
+				deploymentType,
+				nil,
+				nil,
+			)
+			if projectCustomQueries != nil {
+				prometheusHandler.CustomQueries = projectCustomQueries
 			}
+
+			createObjectiveAlertingRule(k, alertingGroupConfig, prometheusHandler, objective, settings, baselineOffset)
 		}
 	}
 
 	return alertingRulesConfig, nil
 }
 
-func createScrapeJobConfig(scrapeConfig *prometheus.ScrapeConfig, config *prometheus.Config, project string, stage string, service string, isCanary bool, isPrimary bool, scrapeInterval time.Duration) {
+// createObjectiveAlertingRule creates or updates the alerting rule of the objective for the deployment type of the
+// prometheus handler
+func createObjectiveAlertingRule(
+	k sdk.IKeptn, alertingGroupConfig *alertingGroup, prometheusHandler *prometheus.Handler, objective *sloObjective, settings alertSettings, baselineOffset string,
+) {
+	deploymentType := prometheusHandler.DeploymentType
+
+	// Get Prometheus Metric Expression
+	end := time.Now()
+	start := end.Add(-180 * time.Second)
+
+	expr, err := prometheusHandler.GetMetricQuery(objective.SLI, start, end)
+	if err != nil || expr == "" {
+		k.Logger().Error("No query defined for SLI " + objective.SLI + " in project " + prometheusHandler.Project)
+		return
+	}
+	k.Logger().Info("expr=" + expr)
+
+	labels := alertingLabel{
+		Severity:     "webhook",
+		PodName:      getDeploymentName(prometheusHandler.Service, deploymentType),
+		Service:      prometheusHandler.Service,
+		Project:      prometheusHandler.Project,
+		Stage:        prometheusHandler.Stage,
+		Deployment:   deploymentType,
+		KeptnManaged: "true",
+	}
+
+	var alertExpr string
+	alertFor := "10m"
+	threshold := getCriteriaThreshold(objective)
+	if objective.Prometheus != nil && objective.Prometheus.BurnRate != nil {
+		alertExpr, err = addBurnRateRules(alertingGroupConfig, prometheusHandler, objective.SLI, *objective.Prometheus.BurnRate, labels)
+		if err != nil {
+			k.Logger().Errorf("Invalid burn rate settings of SLI %s: %v", objective.SLI, err)
+			return
+		}
+		// the short windows prevent the alert from firing after the error budget consumption has stopped
+		alertFor = ""
+		threshold = fmt.Sprintf("%v%%", objective.Prometheus.BurnRate.Target)
+	} else {
+		var skipped []string
+		alertExpr, skipped = getAlertExpr(expr, &objective.SLO, baselineOffset)
+		if len(skipped) > 0 {
+			k.Logger().Warnf("Criteria %v of SLI %s cannot be translated to an alerting rule and are ignored", skipped, objective.SLI)
+		}
+		if alertExpr == "" {
+			k.Logger().Infof("Pass criteria of SLI %s cannot be translated to an alerting rule, because a criteria group contains no translatable criterion", objective.SLI)
+			return
+		}
+	}
+
+	ruleName := objective.SLI
+	newAlertingRule := getAlertingRuleOfGroup(alertingGroupConfig, ruleName, deploymentType)
+	if newAlertingRule == nil {
+		newAlertingRule = &alertingRule{
+			Alert: ruleName,
+		}
+		alertingGroupConfig.Rules = append(alertingGroupConfig.Rules, newAlertingRule)
+	}
+	newAlertingRule.Alert = ruleName
+	newAlertingRule.Expr = alertExpr
+	newAlertingRule.For = alertFor
+	newAlertingRule.Labels = &labels
+	newAlertingRule.Annotations = &alertingAnnotations{
+		Summary:     ruleName,
+		Description: "Pod name {{ $labels.pod_name }}",
+	}
+	applyAlertSettings(newAlertingRule, settings, strings.NewReplacer(
+		"$SLI", objective.SLI,
+		"$THRESHOLD", threshold,
+		"$PROJECT", prometheusHandler.Project,
+		"$STAGE", prometheusHandler.Stage,
+		"$SERVICE", prometheusHandler.Service,
+	))
+}
+
+// createScrapeJobConfig creates or updates the scrape job of the given deployment using the given scrape settings. An
+// existing job that has not been generated by prometheus-service is only replaced if OVERWRITE_UNMANAGED_CONFIG is
+// enabled.
+func createScrapeJobConfig(config *prometheus.Config, project string, stage string, service string, isCanary bool, isPrimary bool, settings scrapeSettings, targetDiscovery string) error {
 	scrapeConfigName := service + "-" + project + "-" + stage
-	var scrapeEndpoint string
+	namespace := project + "-" + stage
+	k8sServiceName := service
 	if isCanary {
 		scrapeConfigName = scrapeConfigName + "-canary"
-		scrapeEndpoint = service + "-canary." + project + "-" + stage + ":80"
+		k8sServiceName = service + "-canary"
 	} else if isPrimary {
 		scrapeConfigName = scrapeConfigName + "-primary"
-		scrapeEndpoint = service + "-primary." + project + "-" + stage + ":80"
-	} else {
-		scrapeEndpoint = service + "." + project + "-" + stage + ":80"
+		k8sServiceName = service + "-primary"
+	}
+	port := settings.Port
+	if port == 0 {
+		port = defaultScrapePort
 	}
+	scrapeEndpoint := k8sServiceName + "." + namespace + ":" + strconv.Itoa(port)
 
-	scrapeConfig = getScrapeConfig(config, scrapeConfigName)
+	scrapeConfig := getScrapeConfig(config, scrapeConfigName)
+	if scrapeConfig != nil && !isManagedScrapeConfig(scrapeConfig) && !overwriteUnmanagedConfig() {
+		return fmt.Errorf("scrape job %s has not been created by %s and is not overwritten, set %s to true to overwrite it", scrapeConfigName, utils.ServiceName, overwriteUnmanagedConfigEnvName)
+	}
 	// (b) if not, create a new scrape config
 	if scrapeConfig == nil {
 		scrapeConfig = &prometheus.ScrapeConfig{}
@@ -417,29 +696,119 @@ func createScrapeJobConfig(scrapeConfig *prometheus.ScrapeConfig, config *promet
 
 	// define scrape job name
 	scrapeConfig.JobName = scrapeConfigName
-	// set scrape interval
-	scrapeConfig.ScrapeInterval = prometheus_model.Duration(scrapeInterval)
-	scrapeConfig.ScrapeTimeout = prometheus_model.Duration(3 * time.Second)
-	// configure metrics path (default: /metrics)
-	scrapeConfig.MetricsPath = utils.EnvVarOrDefault(metricsScrapePathEnvName, "/metrics")
-	scrapeConfig.StaticConfigs = prometheus.Configs{
-		prometheus.StaticConfigLike{
-			Targets: []string{
-				scrapeEndpoint,
+	// set scrape interval and timeout
+	scrapeConfig.ScrapeInterval = settings.ScrapeInterval
+	scrapeConfig.ScrapeTimeout = settings.scrapeTimeout()
+	// configure metrics path (default: /metrics), scheme and URL parameters
+	scrapeConfig.MetricsPath = settings.MetricsPath
+	scrapeConfig.Scheme = settings.Scheme
+	scrapeConfig.Params = settings.Params
+	setScrapeConfigElement(scrapeConfig, "tls_config", settings.TLSConfig)
+	setScrapeConfigElement(scrapeConfig, "basic_auth", settings.BasicAuth)
+
+	if targetDiscovery == scrapeTargetDiscoveryStatic {
+		scrapeConfig.KubernetesSDConfigs = nil
+		scrapeConfig.StaticConfigs = prometheus.Configs{
+			prometheus.StaticConfigLike{
+				Targets: []string{
+					scrapeEndpoint,
+				},
+				Labels: managedStaticConfigLabels(project, stage),
+			},
+		}
+		scrapeConfig.RelabelConfigs = settings.RelabelConfigs
+		return nil
+	}
+
+	// discover every replica in the namespace of the stage, so that each of them is scraped individually
+	scrapeConfig.StaticConfigs = nil
+	scrapeConfig.KubernetesSDConfigs = []*prometheus.UntypedElement{
+		{
+			"role": targetDiscovery,
+			"namespaces": map[string]interface{}{
+				"names": []string{namespace},
 			},
 		},
 	}
+	relabelConfigs := getServiceDiscoveryRelabelConfigs(targetDiscovery, k8sServiceName)
+	if settings.Port != 0 {
+		// only keep the targets of the configured container port
+		relabelConfigs = append(relabelConfigs, &prometheus.UntypedElement{
+			"source_labels": []string{"__meta_kubernetes_pod_container_port_number"},
+			"regex":         strconv.Itoa(settings.Port),
+			"action":        "keep",
+		})
+	}
+	relabelConfigs = append(relabelConfigs, settings.RelabelConfigs...)
+	scrapeConfig.RelabelConfigs = append(relabelConfigs, managedRelabelConfigs(project, stage)...)
+	return nil
 }
 
-func getAlertingRuleOfGroup(alertingGroup *alertingGroup, alertName string) *alertingRule {
+// getServiceDiscoveryRelabelConfigs returns the relabel configs that only keep the targets of the given kubernetes
+// service (role endpoints) or of the pods labelled with it (role pod) and attach the namespace and pod name
+func getServiceDiscoveryRelabelConfigs(targetDiscovery string, k8sServiceName string) []*prometheus.UntypedElement {
+	sourceLabel := "__meta_kubernetes_service_name"
+	if targetDiscovery == scrapeTargetDiscoveryPod {
+		podLabel := utils.EnvVarOrDefault(scrapePodServiceLabelEnvName, "app")
+		sourceLabel = "__meta_kubernetes_pod_label_" + invalidLabelCharRegex.ReplaceAllString(podLabel, "_")
+	}
+
+	return []*prometheus.UntypedElement{
+		{
+			"source_labels": []string{sourceLabel},
+			"regex":         k8sServiceName,
+			"action":        "keep",
+		},
+		{
+			"source_labels": []string{"__meta_kubernetes_namespace"},
+			"target_label":  "namespace",
+			"action":        "replace",
+		},
+		{
+			"source_labels": []string{"__meta_kubernetes_pod_name"},
+			"target_label":  "pod_name",
+			"action":        "replace",
+		},
+	}
+}
+
+// getAlertingRuleOfGroup returns the alerting rule of the deployment type with the given name. A rule without
+// deployment label is treated as rule of the primary deployment.
+func getAlertingRuleOfGroup(alertingGroup *alertingGroup, alertName string, deploymentType string) *alertingRule {
 	for _, rule := range alertingGroup.Rules {
-		if rule.Alert == alertName {
+		if rule.Alert == alertName && getAlertingRuleDeploymentType(rule) == deploymentType {
 			return rule
 		}
 	}
 	return nil
 }
 
+// getAlertingRuleDeploymentType returns the value of the deployment label of the alerting rule
+func getAlertingRuleDeploymentType(rule *alertingRule) string {
+	if rule.Labels == nil || rule.Labels.Deployment == "" {
+		return deploymentTypePrimary
+	}
+	return rule.Labels.Deployment
+}
+
+// removeAlertingRules removes the alerting rules with the given name whose deployment type is not contained in the
+// given deployment types
+func removeAlertingRules(alertingGroup *alertingGroup, alertName string, deploymentTypes []string) {
+	keep := map[string]bool{}
+	for _, deploymentType := range deploymentTypes {
+		keep[deploymentType] = true
+	}
+
+	var rules []*alertingRule
+	for _, rule := range alertingGroup.Rules {
+		if rule.Alert == alertName && !keep[getAlertingRuleDeploymentType(rule)] {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	alertingGroup.Rules = rules
+}
+
 func getAlertingGroup(alertingRulesConfig *alertingRules, groupName string) *alertingGroup {
 	for _, alertingGroup := range alertingRulesConfig.Groups {
 		if alertingGroup.Name == groupName {
@@ -458,18 +827,18 @@ func getScrapeConfig(config *prometheus.Config, name string) *prometheus.ScrapeC
 	return nil
 }
 
-func retrieveSLOs(resourceHandler sdk.ResourceHandler, eventData keptnevents.ConfigureMonitoringEventData, stage string) (*keptnevents.ServiceLevelObjectives, error) {
+func retrieveSLOs(resourceHandler sdk.ResourceHandler, project string, stage string, service string) (*serviceLevelObjectives, error) {
 	resourceScope := configutils.NewResourceScope()
-	resourceScope.Project(eventData.Project)
-	resourceScope.Service(eventData.Service)
+	resourceScope.Project(project)
+	resourceScope.Service(service)
 	resourceScope.Stage(stage)
 	resourceScope.Resource("slo.yaml")
 
 	resource, err := resourceHandler.GetResource(*resourceScope)
 	if err != nil || resource.ResourceContent == "" {
-		return nil, errors.New("No SLO file available for service " + eventData.Service + " in stage " + stage)
+		return nil, errors.New("No SLO file available for service " + service + " in stage " + stage)
 	}
-	var slos keptnevents.ServiceLevelObjectives
+	var slos serviceLevelObjectives
 
 	err = yaml.Unmarshal([]byte(resource.ResourceContent), &slos)
 
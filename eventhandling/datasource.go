@@ -0,0 +1,147 @@
+package eventhandling
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// datasourceResourceURI holds the name of the resource that contains the datasource specific query options
+const datasourceResourceURI = "prometheus/datasource.yaml"
+
+// datasourceConfig holds the query options for all indicators and the overrides for single indicators, e.g.:
+//
+//	partial_response: false
+//	indicators:
+//	  response_time_p95:
+//	    max_source_resolution: 1h
+type datasourceConfig struct {
+	prometheus.QueryOptions `yaml:",inline"`
+	Indicators              map[string]prometheus.QueryOptions `yaml:"indicators,omitempty"`
+}
+
+// getPrometheusDatasource fetches the URL, the type and the type specific options of the prometheus API for the
+// provided project. The type and options are read from the project secret if it exists, otherwise from the environment.
+func getPrometheusDatasource(project string, kubeClient v1.CoreV1Interface) (*prometheus.Datasource, error) {
+	prometheusAPIURL, err := getPrometheusAPIURL(project, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	setting := os.Getenv
+	secret, err := kubeClient.Secrets(env.PodNamespace).Get(context.TODO(), fmt.Sprintf("prometheus-credentials-%s", project), metav1.GetOptions{})
+	if err == nil {
+		setting = func(key string) string {
+			return string(secret.Data[key])
+		}
+	}
+
+	datasource := &prometheus.Datasource{
+		URL:  prometheusAPIURL,
+		Type: prometheus.DatasourceType(strings.ToLower(setting("PROMETHEUS_TYPE"))),
+	}
+
+	if datasource.Type == "" {
+		datasource.Type = prometheus.DatasourceTypePrometheus
+	}
+
+	if !prometheus.IsSupportedDatasourceType(datasource.Type) {
+		return nil, fmt.Errorf("unsupported prometheus type %s for project %s", datasource.Type, project)
+	}
+
+	datasource.Options, err = parseQueryOptions(setting)
+	if err != nil {
+		return nil, err
+	}
+
+	return datasource, nil
+}
+
+// isDefaultDatasource returns true if the datasource is the Prometheus instance configured by PROMETHEUS_ENDPOINT
+func isDefaultDatasource(datasource *prometheus.Datasource) bool {
+	return datasource.Type == prometheus.DatasourceTypePrometheus && datasource.URL == env.PrometheusEndpoint
+}
+
+// getDatasourceConfiguration retrieves the query options considering the configuration on project, stage and
+// service level, where the configuration of a more specific level overrides the one of a less specific level
+func getDatasourceConfiguration(resourceHandler sdk.ResourceHandler, project string, stage string, service string) (*datasourceConfig, error) {
+	contents, err := getResourceContents(resourceHandler, project, stage, service, datasourceResourceURI)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &datasourceConfig{
+		Indicators: map[string]prometheus.QueryOptions{},
+	}
+
+	for _, content := range contents {
+		config := datasourceConfig{}
+		if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", datasourceResourceURI, err)
+		}
+
+		result.QueryOptions = result.QueryOptions.Merge(config.QueryOptions)
+		for indicator, options := range config.Indicators {
+			result.Indicators[indicator] = result.Indicators[indicator].Merge(options)
+		}
+	}
+
+	return result, nil
+}
+
+// parseQueryOptions reads the query options of a datasource using the given lookup function
+func parseQueryOptions(setting func(key string) string) (prometheus.QueryOptions, error) {
+	options := prometheus.QueryOptions{}
+	var err error
+
+	if options.PartialResponse, err = parseOptionalBool(setting, "THANOS_PARTIAL_RESPONSE"); err != nil {
+		return options, err
+	}
+	if options.Dedup, err = parseOptionalBool(setting, "THANOS_DEDUP"); err != nil {
+		return options, err
+	}
+	options.MaxSourceResolution = setting("THANOS_MAX_SOURCE_RESOLUTION")
+	options.ReplicaLabels = parseList(setting("THANOS_REPLICA_LABELS"), ",")
+
+	options.ExtraLabels = parseList(setting("VICTORIAMETRICS_EXTRA_LABELS"), ",")
+	// filters contain commas themselves, e.g. {env="prod",team="a"}, and are therefore separated by semicolons
+	options.ExtraFilters = parseList(setting("VICTORIAMETRICS_EXTRA_FILTERS"), ";")
+	if options.NoCache, err = parseOptionalBool(setting, "VICTORIAMETRICS_NOCACHE"); err != nil {
+		return options, err
+	}
+
+	options.TenantID = setting("MIMIR_TENANT_ID")
+
+	return options, nil
+}
+
+func parseOptionalBool(setting func(key string) string, key string) (*bool, error) {
+	value := setting(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	return &parsed, nil
+}
+
+func parseList(value string, separator string) []string {
+	var result []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
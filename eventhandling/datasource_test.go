@@ -0,0 +1,76 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getDatasourceConfiguration(t *testing.T) {
+	resourceHandler := fakeResourceHandler{
+		resources: map[string]string{
+			"/v1/project/sockshop/resource/prometheus%2Fdatasource.yaml": `
+partial_response: true
+dedup: false
+indicators:
+  response_time_p95:
+    max_source_resolution: 5m
+`,
+			"/v1/project/sockshop/stage/staging/service/carts/resource/prometheus%2Fdatasource.yaml": `
+partial_response: false
+extra_filters:
+  - '{env="staging"}'
+indicators:
+  response_time_p95:
+    dedup: true
+`,
+		},
+	}
+
+	config, err := getDatasourceConfiguration(resourceHandler, "sockshop", "staging", "carts")
+	require.NoError(t, err)
+
+	require.NotNil(t, config.PartialResponse)
+	assert.False(t, *config.PartialResponse)
+	require.NotNil(t, config.Dedup)
+	assert.False(t, *config.Dedup)
+	assert.Equal(t, []string{`{env="staging"}`}, config.ExtraFilters)
+
+	require.Contains(t, config.Indicators, "response_time_p95")
+	assert.Equal(t, "5m", config.Indicators["response_time_p95"].MaxSourceResolution)
+	require.NotNil(t, config.Indicators["response_time_p95"].Dedup)
+	assert.True(t, *config.Indicators["response_time_p95"].Dedup)
+}
+
+func Test_parseQueryOptions(t *testing.T) {
+	settings := map[string]string{
+		"THANOS_PARTIAL_RESPONSE":       "true",
+		"THANOS_MAX_SOURCE_RESOLUTION":  "auto",
+		"THANOS_REPLICA_LABELS":         "replica, rule_replica",
+		"VICTORIAMETRICS_EXTRA_FILTERS": `{env="prod",team="a"}; {app="carts"}`,
+		"VICTORIAMETRICS_NOCACHE":       "true",
+		"MIMIR_TENANT_ID":               "team-a",
+	}
+
+	options, err := parseQueryOptions(func(key string) string {
+		return settings[key]
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, options.PartialResponse)
+	assert.True(t, *options.PartialResponse)
+	assert.Nil(t, options.Dedup)
+	assert.Equal(t, "auto", options.MaxSourceResolution)
+	assert.Equal(t, []string{"replica", "rule_replica"}, options.ReplicaLabels)
+	assert.Equal(t, []string{`{env="prod",team="a"}`, `{app="carts"}`}, options.ExtraFilters)
+	require.NotNil(t, options.NoCache)
+	assert.True(t, *options.NoCache)
+	assert.Equal(t, "team-a", options.TenantID)
+
+	settings["THANOS_DEDUP"] = "maybe"
+	_, err = parseQueryOptions(func(key string) string {
+		return settings[key]
+	})
+	require.Error(t, err)
+}
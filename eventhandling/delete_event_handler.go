@@ -0,0 +1,85 @@
+package eventhandling
+
+import (
+	"fmt"
+
+	"github.com/kelseyhightower/envconfig"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+)
+
+// ServiceDeleteFinishedEvent is sent by Keptn after a service has been deleted
+var ServiceDeleteFinishedEvent = keptnv2.GetFinishedEventType(keptnv2.ServiceDeleteTaskName)
+
+// ProjectDeleteFinishedEvent is sent by Keptn after a project has been deleted
+var ProjectDeleteFinishedEvent = keptnv2.GetFinishedEventType(keptnv2.ProjectDeleteTaskName)
+
+// DeleteMonitoringEventHandler is responsible for removing the monitoring configuration of deleted services and projects
+type DeleteMonitoringEventHandler struct {
+}
+
+// NewDeleteMonitoringEventHandler creates a new DeleteMonitoringEventHandler
+func NewDeleteMonitoringEventHandler() *DeleteMonitoringEventHandler {
+	return &DeleteMonitoringEventHandler{}
+}
+
+// Execute processes an event
+func (eh DeleteMonitoringEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interface{}, *sdk.Error) {
+	k.Logger().Infof("Handling %s event from %s with id: %s and context: %s", *event.Type, *event.Source, event.ID, event.Shkeptncontext)
+
+	if err := envconfig.Process("", &env); err != nil {
+		k.Logger().Error("Failed to process env var: " + err.Error())
+	}
+
+	// errors are only logged, since the finished events of service and project deletions must not be answered
+	eventData := &keptnv2.EventData{}
+	if err := keptnv2.Decode(event.Data, eventData); err != nil {
+		k.Logger().Errorf("Failed to decode %s event: %v", *event.Type, err)
+		return nil, nil
+	}
+
+	prometheusConfigMutex.Lock()
+	defer prometheusConfigMutex.Unlock()
+
+	if err := eh.removeMonitoring(k, *event.Type, *eventData); err != nil {
+		k.Logger().Errorf("Failed to remove the monitoring configuration of project %s: %v", eventData.Project, err)
+	}
+
+	return nil, nil
+}
+
+// removeMonitoring removes the scrape jobs and alerting rules of the deleted service or project
+func (eh DeleteMonitoringEventHandler) removeMonitoring(k sdk.IKeptn, eventType string, eventData keptnv2.EventData) error {
+	if utils.EnvVarOrDefault("CREATE_TARGETS", "true") != "true" {
+		k.Logger().Info("Creation of targets is disabled, skipping removal of the monitoring configuration")
+		return nil
+	}
+
+	if eventData.Project == "" {
+		return fmt.Errorf("event does not contain a project")
+	}
+
+	filter := monitoringFilter{project: eventData.Project}
+	if eventType == ServiceDeleteFinishedEvent {
+		if eventData.Service == "" {
+			return fmt.Errorf("event does not contain a service")
+		}
+		filter.service = eventData.Service
+	}
+
+	mode, operatorHelper, err := getPrometheusConfigMode(k)
+	if err != nil {
+		return err
+	}
+
+	if mode == prometheusConfigModeGit {
+		// the generated resources are removed together with the service or project
+		return nil
+	}
+	if mode == prometheusConfigModeOperator {
+		return cleanupPrometheusOperator(k, operatorHelper, filter)
+	}
+	return cleanupPrometheusConfigMap(k, filter)
+}
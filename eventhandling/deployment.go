@@ -0,0 +1,82 @@
+package eventhandling
+
+import (
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+)
+
+// deploymentTaskName is the name of the shipyard task deploying a service
+const deploymentTaskName = "deployment"
+
+// deploymentStrategyProperty is the property of the deployment task containing the deployment strategy
+const deploymentStrategyProperty = "deploymentstrategy"
+
+const (
+	deploymentTypePrimary     = "primary"
+	deploymentTypeDirect      = "direct"
+	deploymentTypeUserManaged = "user_managed"
+)
+
+// defaultDeploymentType is the deployment type of stages without deployment task. Earlier versions generated the alerts
+// for the primary deployment only, which is kept for stages whose services are deployed by other tasks.
+const defaultDeploymentType = deploymentTypePrimary
+
+// deploymentStrategyTypes maps the deployment strategies of the shipyard to the deployment type of the workload
+// serving the traffic after a deployment
+var deploymentStrategyTypes = map[string]string{
+	"blue_green_service": deploymentTypePrimary,
+	"blue_green":         deploymentTypePrimary,
+	"direct":             deploymentTypeDirect,
+	"user_managed":       deploymentTypeUserManaged,
+}
+
+// getDeploymentTypes returns the deployment types of the workloads serving the traffic in the stage, derived from the
+// deployment strategies of the deployment tasks of its sequences. A deployment task without strategy deploys
+// directly. Stages without deployment task are assumed to run the defaultDeploymentType.
+func getDeploymentTypes(stage keptnv2.Stage) []string {
+	var deploymentTypes []string
+	found := map[string]bool{}
+
+	for _, sequence := range stage.Sequences {
+		for _, task := range sequence.Tasks {
+			if task.Name != deploymentTaskName {
+				continue
+			}
+
+			deploymentType, ok := deploymentStrategyTypes[getTaskProperty(task, deploymentStrategyProperty)]
+			if !ok {
+				deploymentType = deploymentTypeDirect
+			}
+			if !found[deploymentType] {
+				found[deploymentType] = true
+				deploymentTypes = append(deploymentTypes, deploymentType)
+			}
+		}
+	}
+
+	if len(deploymentTypes) == 0 {
+		return []string{defaultDeploymentType}
+	}
+	return deploymentTypes
+}
+
+// getTaskProperty returns the string property of a shipyard task or an empty string if it is not set
+func getTaskProperty(task keptnv2.Task, name string) string {
+	var value interface{}
+	switch properties := task.Properties.(type) {
+	case map[string]interface{}:
+		value = properties[name]
+	case map[interface{}]interface{}:
+		value = properties[name]
+	}
+
+	result, _ := value.(string)
+	return result
+}
+
+// getDeploymentName returns the name of the Kubernetes deployment of the service with the given deployment type
+func getDeploymentName(service string, deploymentType string) string {
+	if deploymentType == deploymentTypePrimary {
+		return service + "-" + deploymentTypePrimary
+	}
+	return service
+}
@@ -0,0 +1,98 @@
+package eventhandling
+
+import (
+	"testing"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const testDeploymentShipyard = `apiVersion: spec.keptn.sh/0.2.2
+kind: Shipyard
+metadata:
+  name: shipyard-sockshop
+spec:
+  stages:
+    - name: dev
+      sequences:
+        - name: delivery
+          tasks:
+            - name: deployment
+              properties:
+                deploymentstrategy: direct
+            - name: test
+    - name: staging
+      sequences:
+        - name: delivery
+          tasks:
+            - name: deployment
+              properties:
+                deploymentstrategy: blue_green_service
+        - name: delivery-direct
+          tasks:
+            - name: deployment
+    - name: production
+      sequences:
+        - name: delivery
+          tasks:
+            - name: deployment
+              properties:
+                deploymentstrategy: user_managed
+    - name: monitoring
+      sequences:
+        - name: remediation
+          tasks:
+            - name: action
+`
+
+func Test_getDeploymentTypes(t *testing.T) {
+	var shipyard keptnv2.Shipyard
+	require.NoError(t, yaml.Unmarshal([]byte(testDeploymentShipyard), &shipyard))
+
+	assert.Equal(t, []string{"direct"}, getDeploymentTypes(shipyard.Spec.Stages[0]))
+	assert.Equal(t, []string{"primary", "direct"}, getDeploymentTypes(shipyard.Spec.Stages[1]))
+	assert.Equal(t, []string{"user_managed"}, getDeploymentTypes(shipyard.Spec.Stages[2]))
+}
+
+func Test_getDeploymentTypesWithoutDeploymentTask(t *testing.T) {
+	var shipyard keptnv2.Shipyard
+	require.NoError(t, yaml.Unmarshal([]byte(testDeploymentShipyard), &shipyard))
+
+	// stages without deployment task keep the primary deployment alerts of earlier versions
+	assert.Equal(t, []string{"primary"}, getDeploymentTypes(shipyard.Spec.Stages[3]))
+	assert.Equal(t, []string{"primary"}, getDeploymentTypes(keptnv2.Stage{Name: "hardening"}))
+}
+
+func Test_createPrometheusAlertsForDeploymentTypes(t *testing.T) {
+	var shipyard keptnv2.Shipyard
+	require.NoError(t, yaml.Unmarshal([]byte(testDeploymentShipyard), &shipyard))
+
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/stage/staging/service/carts/resource/slo.yaml":         testSLO,
+		"/v1/project/sockshop/stage/staging/service/carts/resource/remediation.yaml": "remediations: []",
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+
+	// an existing rule of a deployment type that is no longer deployed is removed
+	existing := alertingRules{Groups: []*alertingGroup{{
+		Name: "carts sockshop-staging alerts",
+		Rules: []*alertingRule{
+			{Alert: "response_time_p95", Labels: &alertingLabel{Deployment: "user_managed", KeptnManaged: "true"}},
+		},
+	}}}
+
+	config, err := ConfigureMonitoringEventHandler{}.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, shipyard.Spec.Stages[1], existing)
+	require.NoError(t, err)
+
+	require.Len(t, config.Groups, 1)
+	rules := config.Groups[0].Rules
+	require.Len(t, rules, 2)
+	assert.Equal(t, "primary", rules[0].Labels.Deployment)
+	assert.Equal(t, "carts-primary", rules[0].Labels.PodName)
+	assert.Equal(t, "direct", rules[1].Labels.Deployment)
+	assert.Equal(t, "carts", rules[1].Labels.PodName)
+	assert.Equal(t, "response_time_p95", rules[1].Alert)
+}
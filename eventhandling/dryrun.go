@@ -0,0 +1,38 @@
+package eventhandling
+
+import (
+	"strings"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+)
+
+const dryRunEnvName = "DRY_RUN"
+
+// dryRunLabel is the label of a configure-monitoring event that enables the dry-run for this event only
+const dryRunLabel = "dryRun"
+
+// isDryRun returns true if the changes of the Prometheus and Alertmanager configuration should only be reported, which
+// is enabled for all events by the DRY_RUN env var or for a single event by its dryRun label
+func isDryRun(event sdk.KeptnEvent) bool {
+	if utils.EnvVarOrDefault(dryRunEnvName, "false") == "true" {
+		return true
+	}
+
+	eventData := &keptnv2.EventData{}
+	if err := keptnv2.Decode(event.Data, eventData); err != nil {
+		return false
+	}
+	return eventData.Labels[dryRunLabel] == "true"
+}
+
+// getDryRunMessage returns the message of the configure-monitoring.finished event containing the unified diffs of the
+// configuration files that would have been changed
+func getDryRunMessage(diffs []string) string {
+	if len(diffs) == 0 {
+		return "Dry run: Prometheus and Alertmanager configuration is up to date"
+	}
+	return "Dry run: the following changes have not been applied\n" + strings.Join(diffs, "")
+}
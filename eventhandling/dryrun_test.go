@@ -0,0 +1,36 @@
+package eventhandling
+
+import (
+	"testing"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isDryRun(t *testing.T) {
+	event := sdk.KeptnEvent{Data: keptnv2.EventData{Project: "sockshop", Service: "carts"}}
+	assert.False(t, isDryRun(event))
+
+	event.Data = keptnv2.EventData{Project: "sockshop", Service: "carts", Labels: map[string]string{dryRunLabel: "true"}}
+	assert.True(t, isDryRun(event))
+
+	t.Setenv(dryRunEnvName, "true")
+	assert.True(t, isDryRun(sdk.KeptnEvent{Data: keptnv2.EventData{Project: "sockshop"}}))
+}
+
+func Test_diffPrometheusConfigMap(t *testing.T) {
+	env.PrometheusConfigFileName = "prometheus.yml"
+	data := map[string]string{
+		"prometheus.yml":     "global:\n  scrape_interval: 15s\n",
+		"alerting_rules.yml": "groups: []\n",
+	}
+
+	diff, err := diffPrometheusConfigMap(data, "global:\n  scrape_interval: 15s\nscrape_configs:\n- job_name: carts-sockshop-dev\n", "alerting_rules.yml", "groups: []\n")
+	require.NoError(t, err)
+	assert.Equal(t, "--- a/prometheus.yml\n+++ b/prometheus.yml\n@@ -1,2 +1,4 @@\n global:\n   scrape_interval: 15s\n+scrape_configs:\n+- job_name: carts-sockshop-dev\n", diff)
+
+	assert.Equal(t, "Dry run: the following changes have not been applied\n"+diff, getDryRunMessage([]string{diff}))
+	assert.Equal(t, "Dry run: Prometheus and Alertmanager configuration is up to date", getDryRunMessage(nil))
+}
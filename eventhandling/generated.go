@@ -0,0 +1,150 @@
+package eventhandling
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"gopkg.in/yaml.v2"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+)
+
+const storeGeneratedConfigEnvName = "STORE_GENERATED_CONFIG"
+
+// generatedScrapeConfigsURI is the resource containing the scrape jobs generated for a service in a stage
+const generatedScrapeConfigsURI = "prometheus/generated/scrape_configs.yaml"
+
+// generatedAlertingRulesURI is the resource containing the alerting rule groups generated for a service in a stage
+const generatedAlertingRulesURI = "prometheus/generated/alerting_rules.yaml"
+
+// serviceResourceWriter creates or updates resources of a service in the Keptn configuration repository
+type serviceResourceWriter interface {
+	UpdateServiceResources(project string, stage string, service string, resources []*models.Resource) (string, error)
+}
+
+// generatedScrapeConfigs is the structure of the generated scrape jobs, which can be used as scrape_configs section of a
+// prometheus.yml
+type generatedScrapeConfigs struct {
+	ScrapeConfigs []*prometheus.ScrapeConfig `yaml:"scrape_configs"`
+}
+
+// storeGeneratedConfigEnabled returns true if the generated scrape jobs and alerting rules should be written to the
+// Keptn configuration repository
+func storeGeneratedConfigEnabled() bool {
+	return utils.EnvVarOrDefault(storeGeneratedConfigEnvName, "false") == "true"
+}
+
+// storeGeneratedConfig writes the scrape jobs and alerting rule groups generated for the service to its resources in
+// each stage of the shipyard, in a dry-run only the diffs to the stored resources are returned
+func (eh ConfigureMonitoringEventHandler) storeGeneratedConfig(k sdk.IKeptn, writer serviceResourceWriter, eventData keptnevents.ConfigureMonitoringEventData, dryRun bool) ([]string, error) {
+	scope := api.NewResourceScope()
+	scope.Project(eventData.Project)
+	scope.Resource("shipyard.yaml")
+
+	shipyard, err := GetShipyard(k.GetResourceHandler(), *scope)
+	if err != nil {
+		return nil, err
+	}
+
+	targetDiscovery, err := getScrapeTargetDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	stageScrapeSettings, err := getStageScrapeSettings(k, shipyard, eventData.Project, eventData.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	for _, stage := range shipyard.Spec.Stages {
+		resources, err := eh.renderGeneratedConfig(k, eventData, stage, stageScrapeSettings[stage.Name], targetDiscovery)
+		if err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			for _, resource := range resources {
+				diff, err := diffGeneratedResource(k.GetResourceHandler(), eventData.Project, stage.Name, eventData.Service, resource)
+				if err != nil {
+					return nil, err
+				}
+				if diff != "" {
+					diffs = append(diffs, diff)
+				}
+			}
+			continue
+		}
+
+		if _, err := writer.UpdateServiceResources(eventData.Project, stage.Name, eventData.Service, resources); err != nil {
+			return nil, fmt.Errorf("could not store generated configuration of service %s in stage %s: %w", eventData.Service, stage.Name, err)
+		}
+		k.Logger().Infof("Stored generated configuration of service %s in stage %s", eventData.Service, stage.Name)
+	}
+
+	return diffs, nil
+}
+
+// renderGeneratedConfig returns the resources containing the scrape jobs and the alerting rule groups generated for
+// the service in the given stage
+func (eh ConfigureMonitoringEventHandler) renderGeneratedConfig(k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData, stage keptnv2.Stage, settings scrapeSettings, targetDiscovery string) ([]*models.Resource, error) {
+	config := &prometheus.Config{}
+	for _, deployment := range []struct{ isCanary, isPrimary bool }{{false, true}, {true, false}, {false, false}} {
+		if err := createScrapeJobConfig(config, eventData.Project, stage.Name, eventData.Service, deployment.isCanary, deployment.isPrimary, settings, targetDiscovery); err != nil {
+			return nil, err
+		}
+	}
+
+	alertingRulesConfig, err := eh.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, stage, alertingRules{})
+	if err != nil {
+		return nil, fmt.Errorf("error configuring prometheus alerts: %w", err)
+	}
+	alertingRulesConfig, err = createSLIRecordingRules(k, eventData, stage, alertingRulesConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring SLI recording rules: %w", err)
+	}
+
+	scrapeConfigsYAML, err := yaml.Marshal(generatedScrapeConfigs{ScrapeConfigs: config.ScrapeConfigs})
+	if err != nil {
+		return nil, err
+	}
+	alertingRulesYAML, err := yaml.Marshal(alertingRulesConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePrometheusConfiguration(string(scrapeConfigsYAML), string(alertingRulesYAML)); err != nil {
+		return nil, err
+	}
+
+	scrapeConfigsURI := generatedScrapeConfigsURI
+	alertingRulesURI := generatedAlertingRulesURI
+	return []*models.Resource{
+		{ResourceURI: &scrapeConfigsURI, ResourceContent: string(scrapeConfigsYAML)},
+		{ResourceURI: &alertingRulesURI, ResourceContent: string(alertingRulesYAML)},
+	}, nil
+}
+
+// diffGeneratedResource returns the unified diff between the stored service resource and its generated content
+func diffGeneratedResource(resourceHandler sdk.ResourceHandler, project string, stage string, service string, resource *models.Resource) (string, error) {
+	scope := api.NewResourceScope()
+	scope.Project(project)
+	scope.Stage(stage)
+	scope.Service(service)
+	scope.Resource(*resource.ResourceURI)
+
+	storedContent := ""
+	stored, err := resourceHandler.GetResource(*scope)
+	if err != nil && !errors.Is(err, api.ResourceNotFoundError) {
+		return "", err
+	}
+	if err == nil && stored != nil {
+		storedContent = stored.ResourceContent
+	}
+	return prometheus.UnifiedDiff(*resource.ResourceURI, storedContent, resource.ResourceContent)
+}
@@ -0,0 +1,90 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testShipyard = `apiVersion: spec.keptn.sh/0.2.2
+kind: Shipyard
+metadata:
+  name: shipyard-sockshop
+spec:
+  stages:
+    - name: dev
+    - name: production
+`
+
+const testSLO = `spec_version: "1.0"
+objectives:
+  - sli: response_time_p95
+    pass:
+      - criteria:
+          - "<=200"
+`
+
+// fakeServiceResourceWriter records the resources written per stage
+type fakeServiceResourceWriter struct {
+	resources map[string][]*models.Resource
+}
+
+func (f *fakeServiceResourceWriter) UpdateServiceResources(_ string, stage string, _ string, resources []*models.Resource) (string, error) {
+	f.resources[stage] = resources
+	return "", nil
+}
+
+func newTestKeptn(resources map[string]string) sdk.IKeptn {
+	fakeKeptn := sdk.NewFakeKeptn("test")
+	fakeKeptn.SetResourceHandler(fakeResourceHandler{resources: resources})
+	return fakeKeptn.Keptn
+}
+
+func Test_storeGeneratedConfig(t *testing.T) {
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/resource/shipyard.yaml":                                   testShipyard,
+		"/v1/project/sockshop/stage/production/service/carts/resource/slo.yaml":         testSLO,
+		"/v1/project/sockshop/stage/production/service/carts/resource/remediation.yaml": "remediations: []",
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+	writer := &fakeServiceResourceWriter{resources: map[string][]*models.Resource{}}
+
+	diffs, err := ConfigureMonitoringEventHandler{}.storeGeneratedConfig(k, writer, eventData, false)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	require.Len(t, writer.resources, 2)
+	require.Len(t, writer.resources["dev"], 2)
+	assert.Equal(t, generatedScrapeConfigsURI, *writer.resources["dev"][0].ResourceURI)
+	assert.Contains(t, writer.resources["dev"][0].ResourceContent, "job_name: carts-sockshop-dev-primary")
+	assert.Contains(t, writer.resources["dev"][0].ResourceContent, "job_name: carts-sockshop-dev-canary")
+	assert.Equal(t, generatedAlertingRulesURI, *writer.resources["dev"][1].ResourceURI)
+	assert.Equal(t, "groups: []\n", writer.resources["dev"][1].ResourceContent)
+
+	assert.Contains(t, writer.resources["production"][1].ResourceContent, "name: carts sockshop-production alerts")
+	assert.Contains(t, writer.resources["production"][1].ResourceContent, "alert: response_time_p95")
+}
+
+func Test_storeGeneratedConfigDryRun(t *testing.T) {
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/resource/shipyard.yaml":                                                        testShipyard,
+		"/v1/project/sockshop/stage/dev/service/carts/resource/prometheus%2Fgenerated%2Falerting_rules.yaml": "groups: []\n",
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+	writer := &fakeServiceResourceWriter{resources: map[string][]*models.Resource{}}
+
+	diffs, err := ConfigureMonitoringEventHandler{}.storeGeneratedConfig(k, writer, eventData, true)
+	require.NoError(t, err)
+	assert.Empty(t, writer.resources)
+
+	// the unchanged alerting rules of dev are not part of the diffs
+	require.Len(t, diffs, 3)
+	assert.Contains(t, diffs[0], "+++ b/"+generatedScrapeConfigsURI)
+	assert.Contains(t, diffs[0], "+- job_name: carts-sockshop-dev-primary")
+	assert.Contains(t, diffs[1], "+- job_name: carts-sockshop-production-primary")
+	assert.Contains(t, diffs[2], "+++ b/"+generatedAlertingRulesURI)
+}
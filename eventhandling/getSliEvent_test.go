@@ -40,6 +40,8 @@ const eventJSON = `
 }
 `
 
+const expectedThroughputQuery = "sum(rate(http_requests_total{job='carts-sockshop-staging-canary'}[76s]))"
+
 func Test_retrieveMetrics(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -72,15 +74,16 @@ func Test_retrieveMetrics(t *testing.T) {
 		returnValue, prometheusAPI.Warnings{}, nil,
 	)
 
-	sliResults := retrieveMetrics(&handler, eventData)
+	sliResults, indicatorsWithWarnings := retrieveMetrics(&handler, eventData)
 
 	assert.Len(t, sliResults, 1)
+	assert.Empty(t, indicatorsWithWarnings)
 	assert.Contains(t, sliResults, &keptnv2.SLIResult{
 		Metric:        prometheusUtils.Throughput,
 		Value:         sliValue,
 		ComparedValue: 0,
 		Success:       true,
-		Message:       "",
+		Message:       "query: " + expectedThroughputQuery + ", evaluated at: 2022-04-06T14:36:19Z",
 	})
 }
 
@@ -118,15 +121,16 @@ func Test_retrieveMetricsWithMultipleValues(t *testing.T) {
 		returnValue, prometheusAPI.Warnings{}, nil,
 	)
 
-	sliResults := retrieveMetrics(&handler, eventData)
+	sliResults, indicatorsWithWarnings := retrieveMetrics(&handler, eventData)
 
 	assert.Len(t, sliResults, 1)
+	assert.Empty(t, indicatorsWithWarnings)
 	assert.Contains(t, sliResults, &keptnv2.SLIResult{
 		Metric:        prometheusUtils.Throughput,
 		Value:         0,
 		ComparedValue: 0,
 		Success:       false,
-		Message:       prometheusUtils.ErrMultipleValues.Error(),
+		Message:       prometheusUtils.ErrMultipleValues.Error() + ", query: " + expectedThroughputQuery + ", evaluated at: 2022-04-06T14:36:19Z",
 	})
 }
 
@@ -155,14 +159,59 @@ func Test_retrieveMetricsWithNoValue(t *testing.T) {
 		prometheusModel.Vector{}, prometheusAPI.Warnings{}, nil,
 	)
 
-	sliResults := retrieveMetrics(&handler, eventData)
+	sliResults, indicatorsWithWarnings := retrieveMetrics(&handler, eventData)
 
 	assert.Len(t, sliResults, 1)
+	assert.Empty(t, indicatorsWithWarnings)
 	assert.Contains(t, sliResults, &keptnv2.SLIResult{
 		Metric:        prometheusUtils.Throughput,
 		Value:         0,
 		ComparedValue: 0,
 		Success:       false,
-		Message:       prometheusUtils.ErrNoValues.Error(),
+		Message:       prometheusUtils.ErrNoValues.Error() + ", query: " + expectedThroughputQuery + ", evaluated at: 2022-04-06T14:36:19Z",
+	})
+}
+
+func Test_retrieveMetricsWithWarnings(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	incomingEvent := &cloudevents.Event{}
+
+	err := json.Unmarshal([]byte(eventJSON), incomingEvent)
+	require.NoError(t, err)
+
+	eventData := &keptnv2.GetSLITriggeredEventData{}
+	err = incomingEvent.DataAs(eventData)
+	require.NoError(t, err)
+
+	apiMock := prometheusfake.NewMockAPI(mockCtrl)
+	handler := prometheusUtils.Handler{
+		Project:       eventData.Project,
+		Stage:         eventData.Stage,
+		Service:       eventData.Service,
+		PrometheusAPI: apiMock,
+	}
+
+	returnValue := prometheusModel.Vector{
+		{
+			Value: prometheusModel.SampleValue(1.5),
+		},
+	}
+
+	apiMock.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(
+		returnValue, prometheusAPI.Warnings{"partial response", "results truncated"}, nil,
+	)
+
+	sliResults, indicatorsWithWarnings := retrieveMetrics(&handler, eventData)
+
+	assert.Len(t, sliResults, 1)
+	assert.Equal(t, []string{prometheusUtils.Throughput}, indicatorsWithWarnings)
+	assert.Contains(t, sliResults, &keptnv2.SLIResult{
+		Metric:        prometheusUtils.Throughput,
+		Value:         1.5,
+		ComparedValue: 0,
+		Success:       true,
+		Message:       "warnings: partial response; results truncated, query: " + expectedThroughputQuery + ", evaluated at: 2022-04-06T14:36:19Z",
 	})
 }
@@ -15,6 +15,7 @@ import (
 	"log"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/keptn-contrib/prometheus-service/utils"
 
@@ -43,6 +44,8 @@ type prometheusCredentials struct {
 
 var env utils.EnvConfig
 
+const downgradeSLIWarningsEnvName = "DOWNGRADE_SLI_WARNINGS"
+
 // Execute processes an event
 func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interface{}, *sdk.Error) {
 	if err := envconfig.Process("", &env); err != nil {
@@ -54,8 +57,8 @@ func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interf
 		return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "failed to decode get-sli.triggered event: " + err.Error()}
 	}
 
-	// get prometheus API URL for the provided Project from Kubernetes Config Map
-	prometheusAPIURL, err := getPrometheusAPIURL(eventData.Project, eh.kubeClient.CoreV1())
+	// get prometheus datasource for the provided Project from Kubernetes Secret
+	datasource, err := getPrometheusDatasource(eventData.Project, eh.kubeClient.CoreV1())
 	if err != nil {
 		return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "failed to get Prometheus API URL: " + err.Error()}
 	}
@@ -73,13 +76,29 @@ func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interf
 
 	// create a new Prometheus Handler
 	prometheusHandler := prometheus.NewPrometheusHandler(
-		prometheusAPIURL,
+		datasource.URL,
 		&eventData.EventData,
 		deployment,
 		eventData.Labels,
 		eventData.GetSLI.CustomFilters,
 	)
 
+	// use the datasource specific API for Prometheus compatible backends (e.g., Thanos, VictoriaMetrics, Mimir)
+	if datasource.Type != prometheus.DatasourceTypePrometheus {
+		config, err := getDatasourceConfiguration(k.GetResourceHandler(), eventData.Project, eventData.Stage, eventData.Service)
+		if err != nil {
+			return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "unable to retrieve datasource configuration: " + err.Error()}
+		}
+
+		datasource.Options = datasource.Options.Merge(config.QueryOptions)
+		datasource.IndicatorOptions = config.Indicators
+
+		prometheusHandler.PrometheusAPI, err = datasource.NewAPI()
+		if err != nil {
+			return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: fmt.Sprintf("unable to create %s API client: %s", datasource.Type, err.Error())}
+		}
+	}
+
 	// get SLI queries (from SLI.yaml)
 	projectCustomQueries, err := getCustomQueries(k.GetResourceHandler(), eventData.Project, eventData.Stage, eventData.Service)
 	if err != nil {
@@ -91,26 +110,43 @@ func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interf
 		prometheusHandler.CustomQueries = projectCustomQueries
 	}
 
-	// retrieve metrics from prometheus
-	sliResults := retrieveMetrics(prometheusHandler, eventData)
+	// use the series recorded by the SLI recording rules for queries matching a recorded window
+	prometheusHandler.RecordedQueries, err = getRecordedSeriesSelectors(datasource, eventData.Project, eventData.Stage, eventData.Service, projectCustomQueries)
+	if err != nil {
+		return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "invalid SLI recording windows: " + err.Error()}
+	}
 
-	// If we hand any problem retrieving an SLI value, we set the result of the overall .finished event
-	// to Warning, if all fail ResultFailed is set for the event
-	finalSLIEventResult := keptnv2.ResultPass
+	// get the policy used to aggregate the SLI results (from env and SLI.yaml)
+	policy, err := getResultPolicy(k.GetResourceHandler(), eventData.Project, eventData.Stage, eventData.Service)
+	if err != nil {
+		return nil, &sdk.Error{Err: err, StatusType: keptnv2.StatusErrored, ResultType: keptnv2.ResultFailed, Message: "invalid result policy: " + err.Error()}
+	}
 
-	if len(sliResults) > 0 {
-		sliResultsFailed := 0
-		for _, sliResult := range sliResults {
-			if !sliResult.Success {
-				sliResultsFailed++
+	keySLIs := map[string]bool{}
+	if policy.Policy == resultPolicyKeySLI {
+		slos, err := retrieveSLOs(k.GetResourceHandler(), eventData.Project, eventData.Stage, eventData.Service)
+		if err != nil {
+			log.Printf("Unable to retrieve key SLIs: %s", err.Error())
+		} else {
+			for _, objective := range slos.Objectives {
+				keySLIs[objective.SLI] = objective.KeySLI
 			}
 		}
+	}
 
-		if sliResultsFailed > 0 && sliResultsFailed < len(sliResults) {
-			finalSLIEventResult = keptnv2.ResultWarning
-		} else if sliResultsFailed == len(sliResults) {
-			finalSLIEventResult = keptnv2.ResultFailed
-		}
+	// retrieve metrics from prometheus
+	sliResults, indicatorsWithWarnings := retrieveMetrics(prometheusHandler, eventData)
+
+	finalSLIEventResult, message := policy.aggregate(sliResults, keySLIs)
+
+	// optionally downgrade the result if the Prometheus API returned warnings (e.g., partial responses)
+	downgradeWarnings := utils.EnvVarOrDefault(downgradeSLIWarningsEnvName, "false") == "true"
+	if downgradeWarnings && len(indicatorsWithWarnings) > 0 && finalSLIEventResult == keptnv2.ResultPass {
+		finalSLIEventResult = keptnv2.ResultWarning
+	}
+
+	if len(indicatorsWithWarnings) > 0 {
+		message += "; Prometheus API returned warnings for: " + strings.Join(indicatorsWithWarnings, ", ")
 	}
 
 	// construct finished event data
@@ -122,6 +158,7 @@ func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interf
 			Stage:   eventData.Stage,
 			Service: eventData.Service,
 			Labels:  eventData.Labels,
+			Message: message,
 		},
 		GetSLI: keptnv2.GetSLIFinished{
 			IndicatorValues: sliResults,
@@ -130,38 +167,61 @@ func (eh GetSliEventHandler) Execute(k sdk.IKeptn, event sdk.KeptnEvent) (interf
 		},
 	}
 
-	if getSliFinishedEventData.EventData.Result == keptnv2.ResultFailed {
-		getSliFinishedEventData.EventData.Message = "unable to retrieve metrics"
-	}
-
 	return getSliFinishedEventData, nil
 }
 
-func retrieveMetrics(prometheusHandler *prometheus.Handler, eventData *keptnv2.GetSLITriggeredEventData) []*keptnv2.SLIResult {
+// retrieveMetrics queries all indicators of the event and returns their results as well as the names of the
+// indicators for which the Prometheus API returned warnings
+func retrieveMetrics(prometheusHandler *prometheus.Handler, eventData *keptnv2.GetSLITriggeredEventData) ([]*keptnv2.SLIResult, []string) {
 	log.Printf("Retrieving Prometheus metrics")
 
 	var sliResults []*keptnv2.SLIResult
+	var indicatorsWithWarnings []string
 
 	for _, indicator := range eventData.GetSLI.Indicators {
 		log.Println("retrieveMetrics: Fetching indicator: " + indicator)
-		sliValue, err := prometheusHandler.GetSLIValue(indicator, eventData.GetSLI.Start, eventData.GetSLI.End)
+		sliValue, err := prometheusHandler.QuerySLIValue(indicator, eventData.GetSLI.Start, eventData.GetSLI.End)
+		if sliValue != nil && len(sliValue.Warnings) > 0 {
+			indicatorsWithWarnings = append(indicatorsWithWarnings, indicator)
+		}
+
 		if err != nil {
 			sliResults = append(sliResults, &keptnv2.SLIResult{
 				Metric:  indicator,
 				Value:   0,
 				Success: false,
-				Message: err.Error(),
+				Message: getSLIResultMessage(sliValue, err),
 			})
 		} else {
 			sliResults = append(sliResults, &keptnv2.SLIResult{
 				Metric:  indicator,
-				Value:   sliValue,
+				Value:   sliValue.Value,
 				Success: true,
+				Message: getSLIResultMessage(sliValue, nil),
 			})
 		}
 	}
 
-	return sliResults
+	return sliResults, indicatorsWithWarnings
+}
+
+// getSLIResultMessage builds the message of an SLI result containing the error (if any), the warnings returned by
+// the Prometheus API, the rendered query and the evaluation timestamp
+func getSLIResultMessage(sliValue *prometheus.SLIValue, err error) string {
+	var parts []string
+	if err != nil {
+		parts = append(parts, err.Error())
+	}
+
+	if sliValue != nil {
+		if len(sliValue.Warnings) > 0 {
+			parts = append(parts, "warnings: "+strings.Join(sliValue.Warnings, "; "))
+		}
+		parts = append(parts, fmt.Sprintf("query: %s", sliValue.Query))
+		parts = append(parts, fmt.Sprintf("evaluated at: %s", sliValue.Timestamp.UTC().Format(time.RFC3339)))
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 func getCustomQueries(resourceHandler sdk.ResourceHandler, project string, stage string, service string) (map[string]string, error) {
@@ -0,0 +1,345 @@
+package eventhandling
+
+import (
+	"fmt"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+)
+
+// prometheusConfigModeAuto uses the Prometheus Operator if its CRDs are installed and the configmap otherwise
+const prometheusConfigModeAuto = "auto"
+
+// prometheusConfigModeConfigMap modifies the prometheus.yml and the alerting rules in the Prometheus configmap
+const prometheusConfigModeConfigMap = "configmap"
+
+// prometheusConfigModeOperator creates ServiceMonitor/PodMonitor and PrometheusRule objects of the Prometheus Operator
+const prometheusConfigModeOperator = "operator"
+
+// prometheusConfigModeGit only writes the generated scrape jobs and alerting rules to the Keptn configuration
+// repository, e.g., if the Prometheus configuration is deployed via GitOps
+const prometheusConfigModeGit = "git"
+
+// getPrometheusConfigMode returns how Prometheus is configured (configmap, operator or git) based on the
+// PROMETHEUS_CONFIG_MODE env var and, in auto mode, on the presence of the Prometheus Operator CRDs
+func getPrometheusConfigMode(k sdk.IKeptn) (string, *prometheus.OperatorHelper, error) {
+	mode := env.PrometheusConfigMode
+	if mode == "" {
+		mode = prometheusConfigModeAuto
+	}
+
+	if mode == prometheusConfigModeConfigMap || mode == prometheusConfigModeGit {
+		return mode, nil, nil
+	}
+	if mode != prometheusConfigModeAuto && mode != prometheusConfigModeOperator {
+		return "", nil, fmt.Errorf("invalid value %s for PROMETHEUS_CONFIG_MODE", mode)
+	}
+
+	operatorHelper, err := newOperatorHelper()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if mode == prometheusConfigModeOperator {
+		return mode, operatorHelper, nil
+	}
+
+	installed, err := operatorHelper.IsInstalled(prometheus.ServiceMonitorResource, prometheus.PodMonitorResource, prometheus.PrometheusRuleResource)
+	if err != nil {
+		k.Logger().Errorf("Unable to detect the Prometheus Operator, falling back to configmap mode: %v", err)
+		return prometheusConfigModeConfigMap, nil, nil
+	}
+	if installed {
+		k.Logger().Info("Detected Prometheus Operator CRDs, using operator mode")
+		return prometheusConfigModeOperator, operatorHelper, nil
+	}
+	return prometheusConfigModeConfigMap, nil, nil
+}
+
+// getAlertManagerConfigMode returns whether the Alertmanager is configured via its configmap or via an
+// AlertmanagerConfig of the Prometheus Operator (configmap or operator) based on the ALERT_MANAGER_CONFIG_MODE env var
+// and, in auto mode, on the presence of the AlertmanagerConfig CRD. The given operator helper is reused if it is set.
+func getAlertManagerConfigMode(k sdk.IKeptn, operatorHelper *prometheus.OperatorHelper) (string, *prometheus.OperatorHelper, error) {
+	mode := env.AlertManagerConfigMode
+	if mode == "" {
+		mode = prometheusConfigModeAuto
+	}
+
+	if mode == prometheusConfigModeConfigMap {
+		return mode, nil, nil
+	}
+	if mode != prometheusConfigModeAuto && mode != prometheusConfigModeOperator {
+		return "", nil, fmt.Errorf("invalid value %s for ALERT_MANAGER_CONFIG_MODE", mode)
+	}
+
+	if operatorHelper == nil {
+		var err error
+		operatorHelper, err = newOperatorHelper()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if mode == prometheusConfigModeOperator {
+		return mode, operatorHelper, nil
+	}
+
+	installed, err := operatorHelper.IsInstalled(prometheus.AlertmanagerConfigResource)
+	if err != nil {
+		k.Logger().Errorf("Unable to detect the AlertmanagerConfig CRD, falling back to configmap mode: %v", err)
+		return prometheusConfigModeConfigMap, nil, nil
+	}
+	if installed {
+		k.Logger().Info("Detected AlertmanagerConfig CRD, configuring the Alertmanager using the Prometheus Operator")
+		return prometheusConfigModeOperator, operatorHelper, nil
+	}
+	return prometheusConfigModeConfigMap, nil, nil
+}
+
+// newOperatorHelper returns an OperatorHelper using the in-cluster Kubernetes clients
+func newOperatorHelper() (*prometheus.OperatorHelper, error) {
+	kubeAPI, err := utils.GetKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := utils.GetDynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	return prometheus.NewOperatorHelper(dynamicClient, kubeAPI.Discovery()), nil
+}
+
+// alertmanagerConfigSecretName is the name of the secret containing the credentials of the webhook of the
+// AlertmanagerConfig
+const alertmanagerConfigSecretName = utils.ServiceName + "-webhook"
+
+// configureAlertmanagerConfig creates or updates the AlertmanagerConfig containing the receiver and the route
+// forwarding alerts to the prometheus-service running in the given namespace
+func configureAlertmanagerConfig(k sdk.IKeptn, operatorHelper *prometheus.OperatorHelper, namespace string) error {
+	k.Logger().Info("Configuring Prometheus AlertManager using an AlertmanagerConfig...")
+
+	objectLabels, err := k8slabels.ConvertSelectorToLabelsMap(env.PrometheusOperatorLabels)
+	if err != nil {
+		return fmt.Errorf("invalid value for PROMETHEUS_OPERATOR_LABELS: %w", err)
+	}
+	integration, err := getAlertManagerIntegration(namespace)
+	if err != nil {
+		return err
+	}
+
+	config, err := newAlertmanagerConfig(env.AlertManagerNamespace, objectLabels, integration)
+	if err != nil {
+		return err
+	}
+
+	operatorHelper.OverwriteUnmanaged = overwriteUnmanagedConfig()
+
+	// the credentials of the webhook are referenced by the AlertmanagerConfig and have to be in its namespace
+	if secretData := integration.WebhookSecretData(); secretData != nil {
+		secret := prometheus.NewSecretObject(env.AlertManagerNamespace, alertmanagerConfigSecretName, config.GetLabels(), secretData)
+		if err := operatorHelper.Apply(prometheus.SecretResource, secret); err != nil {
+			return err
+		}
+	}
+
+	return operatorHelper.Apply(prometheus.AlertmanagerConfigResource, config)
+}
+
+// newAlertmanagerConfig returns an AlertmanagerConfig named like the service containing the receiver and the route of
+// the given integration
+func newAlertmanagerConfig(namespace string, objectLabels map[string]string, integration prometheus.AlertManagerIntegration) (*unstructured.Unstructured, error) {
+	spec, err := integration.AlertmanagerConfigSpec(alertmanagerConfigSecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for key, value := range objectLabels {
+		labels[key] = value
+	}
+	labels[prometheus.ManagedLabel] = "true"
+
+	config := prometheus.NewOperatorObject("AlertmanagerConfig", namespace, utils.ServiceName, labels, spec)
+	config.SetAPIVersion(prometheus.AlertmanagerConfigGroupVersion)
+	return config, nil
+}
+
+// configurePrometheusOperator creates or updates a ServiceMonitor (or PodMonitor) for each deployment of the service
+// in each stage and a PrometheusRule containing the alerting rules of each stage
+func (eh ConfigureMonitoringEventHandler) configurePrometheusOperator(k sdk.IKeptn, operatorHelper *prometheus.OperatorHelper, eventData keptnevents.ConfigureMonitoringEventData) error {
+	scope := api.NewResourceScope()
+	scope.Project(eventData.Project)
+	scope.Resource("shipyard.yaml")
+
+	shipyard, err := GetShipyard(k.GetResourceHandler(), *scope)
+	if err != nil {
+		return err
+	}
+
+	objectLabels, err := k8slabels.ConvertSelectorToLabelsMap(env.PrometheusOperatorLabels)
+	if err != nil {
+		return fmt.Errorf("invalid value for PROMETHEUS_OPERATOR_LABELS: %w", err)
+	}
+
+	targetDiscovery, err := getScrapeTargetDiscovery()
+	if err != nil {
+		return err
+	}
+	stageScrapeSettings, err := getStageScrapeSettings(k, shipyard, eventData.Project, eventData.Service)
+	if err != nil {
+		return err
+	}
+	operatorHelper.OverwriteUnmanaged = overwriteUnmanagedConfig()
+
+	for _, stage := range shipyard.Spec.Stages {
+		for _, suffix := range []string{"-primary", "-canary", ""} {
+			monitor := newMonitor(env.PrometheusNamespace, eventData.Project, stage.Name, eventData.Service, suffix, objectLabels, stageScrapeSettings[stage.Name], targetDiscovery)
+
+			resource := prometheus.ServiceMonitorResource
+			if targetDiscovery == scrapeTargetDiscoveryPod {
+				resource = prometheus.PodMonitorResource
+			}
+			if err := operatorHelper.Apply(resource, monitor); err != nil {
+				return err
+			}
+		}
+
+		alertingRulesConfig, err := eh.createPrometheusAlertsIfSLOsAndRemediationDefined(k, eventData, stage, alertingRules{})
+		if err != nil {
+			return fmt.Errorf("error configuring prometheus alerts: %w", err)
+		}
+		alertingRulesConfig, err = createSLIRecordingRules(k, eventData, stage, alertingRulesConfig)
+		if err != nil {
+			return fmt.Errorf("error configuring SLI recording rules: %w", err)
+		}
+
+		if len(alertingRulesConfig.Groups) == 0 {
+			continue
+		}
+
+		rule, err := newPrometheusRule(env.PrometheusNamespace, eventData.Project, stage.Name, eventData.Service, objectLabels, alertingRulesConfig)
+		if err != nil {
+			return err
+		}
+		if err := operatorHelper.Apply(prometheus.PrometheusRuleResource, rule); err != nil {
+			return err
+		}
+	}
+
+	// remove the objects of stages that are no longer part of the shipyard
+	return cleanupPrometheusOperator(k, operatorHelper, getRemovedStagesFilter(shipyard, eventData))
+}
+
+// newMonitor returns a ServiceMonitor (or a PodMonitor if pods are discovered directly) that scrapes the deployment
+// <service><suffix> in the namespace <project>-<stage> using the job name of the corresponding scrape config. TLS, basic
+// auth and relabel configs of the scrape settings are not supported, since the operator expects them in another format.
+func newMonitor(namespace string, project string, stage string, service string, suffix string, objectLabels map[string]string, settings scrapeSettings, targetDiscovery string) *unstructured.Unstructured {
+	jobName := service + "-" + project + "-" + stage + suffix
+	k8sServiceName := service + suffix
+	objectLabels = getOperatorObjectLabels(objectLabels, project, stage, service)
+
+	endpoint := map[string]interface{}{
+		"path":          settings.MetricsPath,
+		"interval":      settings.ScrapeInterval.String(),
+		"scrapeTimeout": settings.scrapeTimeout().String(),
+		"relabelings": []interface{}{
+			map[string]interface{}{
+				"targetLabel": "job",
+				"replacement": jobName,
+				"action":      "replace",
+			},
+			map[string]interface{}{
+				"sourceLabels": []interface{}{"__meta_kubernetes_pod_name"},
+				"targetLabel":  "pod_name",
+				"action":       "replace",
+			},
+		},
+	}
+
+	if settings.Scheme != "" {
+		endpoint["scheme"] = settings.Scheme
+	}
+	if settings.Port != 0 {
+		endpoint["targetPort"] = int64(settings.Port)
+	}
+	if len(settings.Params) > 0 {
+		params := map[string]interface{}{}
+		for key, values := range settings.Params {
+			var list []interface{}
+			for _, value := range values {
+				list = append(list, value)
+			}
+			params[key] = list
+		}
+		endpoint["params"] = params
+	}
+
+	spec := map[string]interface{}{
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{project + "-" + stage},
+		},
+	}
+
+	if targetDiscovery == scrapeTargetDiscoveryPod {
+		spec["selector"] = map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				utils.EnvVarOrDefault(scrapePodServiceLabelEnvName, "app"): k8sServiceName,
+			},
+		}
+		spec["podMetricsEndpoints"] = []interface{}{endpoint}
+		return prometheus.NewOperatorObject("PodMonitor", namespace, jobName, objectLabels, spec)
+	}
+
+	// ServiceMonitors select services by label, therefore all services are selected and filtered by name
+	endpoint["relabelings"] = append([]interface{}{
+		map[string]interface{}{
+			"sourceLabels": []interface{}{"__meta_kubernetes_service_name"},
+			"regex":        k8sServiceName,
+			"action":       "keep",
+		},
+	}, endpoint["relabelings"].([]interface{})...)
+
+	spec["selector"] = map[string]interface{}{}
+	spec["endpoints"] = []interface{}{endpoint}
+	return prometheus.NewOperatorObject("ServiceMonitor", namespace, jobName, objectLabels, spec)
+}
+
+// newPrometheusRule returns a PrometheusRule containing the given alerting rule groups
+func newPrometheusRule(namespace string, project string, stage string, service string, objectLabels map[string]string, alertingRulesConfig alertingRules) (*unstructured.Unstructured, error) {
+	// convert the rules using their yaml representation, which is the one used in the prometheus configmap
+	alertingRulesYAML, err := yaml.Marshal(alertingRulesConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	objectLabels = getOperatorObjectLabels(objectLabels, project, stage, service)
+
+	spec := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal(alertingRulesYAML, &spec); err != nil {
+		return nil, err
+	}
+
+	return prometheus.NewOperatorObject("PrometheusRule", namespace, service+"-"+project+"-"+stage+"-alerts", objectLabels, spec), nil
+}
+
+// getOperatorObjectLabels returns the given labels extended by the labels identifying the Keptn service, which are used
+// to find the objects of deleted services and stages
+func getOperatorObjectLabels(objectLabels map[string]string, project string, stage string, service string) map[string]string {
+	result := map[string]string{}
+	for key, value := range objectLabels {
+		result[key] = value
+	}
+	result[prometheus.ManagedLabel] = "true"
+	result[prometheus.ProjectLabel] = project
+	result[prometheus.StageLabel] = stage
+	result[prometheus.ServiceLabel] = service
+	return result
+}
@@ -0,0 +1,158 @@
+package eventhandling
+
+import (
+	"testing"
+	"time"
+
+	prometheus_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+func Test_newMonitorServiceMonitor(t *testing.T) {
+	monitor := newMonitor("monitoring", "sockshop", "production", "carts", "-primary", map[string]string{"release": "prometheus"}, scrapeSettings{ScrapeInterval: prometheus_model.Duration(10 * time.Second), MetricsPath: "/metrics"}, scrapeTargetDiscoveryStatic)
+
+	assert.Equal(t, "ServiceMonitor", monitor.GetKind())
+	assert.Equal(t, "monitoring", monitor.GetNamespace())
+	assert.Equal(t, "carts-sockshop-production-primary", monitor.GetName())
+	assert.Equal(t, map[string]string{
+		"release":          "prometheus",
+		"keptn.sh/managed": "true",
+		"keptn.sh/project": "sockshop",
+		"keptn.sh/stage":   "production",
+		"keptn.sh/service": "carts",
+	}, monitor.GetLabels())
+
+	spec := monitor.Object["spec"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"matchNames": []interface{}{"sockshop-production"}}, spec["namespaceSelector"])
+
+	endpoints := spec["endpoints"].([]interface{})
+	require.Len(t, endpoints, 1)
+	endpoint := endpoints[0].(map[string]interface{})
+	assert.Equal(t, "10s", endpoint["interval"])
+
+	relabelings := endpoint["relabelings"].([]interface{})
+	require.Len(t, relabelings, 3)
+	assert.Equal(t, map[string]interface{}{
+		"sourceLabels": []interface{}{"__meta_kubernetes_service_name"},
+		"regex":        "carts-primary",
+		"action":       "keep",
+	}, relabelings[0])
+	assert.Equal(t, "carts-sockshop-production-primary", relabelings[1].(map[string]interface{})["replacement"])
+
+	// the object has to be convertible to JSON
+	_, err := monitor.MarshalJSON()
+	require.NoError(t, err)
+}
+
+func Test_newMonitorPodMonitor(t *testing.T) {
+	monitor := newMonitor("monitoring", "sockshop", "dev", "carts", "", nil, testScrapeSettings, scrapeTargetDiscoveryPod)
+
+	assert.Equal(t, "PodMonitor", monitor.GetKind())
+	assert.Equal(t, "carts-sockshop-dev", monitor.GetName())
+	assert.Equal(t, map[string]string{"keptn.sh/managed": "true", "keptn.sh/project": "sockshop", "keptn.sh/stage": "dev", "keptn.sh/service": "carts"}, monitor.GetLabels())
+
+	spec := monitor.Object["spec"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"matchLabels": map[string]interface{}{"app": "carts"}}, spec["selector"])
+	assert.Len(t, spec["podMetricsEndpoints"], 1)
+	assert.NotContains(t, spec, "endpoints")
+}
+
+func Test_newPrometheusRule(t *testing.T) {
+	rules := alertingRules{
+		Groups: []*alertingGroup{
+			{
+				Name: "sockshop production carts alerts",
+				Rules: []*alertingRule{
+					{
+						Alert: "response_time_p95",
+						Expr:  "histogram_quantile(0.95, ...) > 200",
+						For:   "5m",
+						Labels: &alertingLabel{
+							Severity: "webhook",
+							Service:  "carts",
+						},
+						Annotations: &alertingAnnotations{
+							Summary:     "response_time_p95",
+							Description: "Pod name {{ $labels.pod_name }}",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rule, err := newPrometheusRule("monitoring", "sockshop", "production", "carts", nil, rules)
+	require.NoError(t, err)
+
+	assert.Equal(t, "PrometheusRule", rule.GetKind())
+	assert.Equal(t, "carts-sockshop-production-alerts", rule.GetName())
+
+	groups := rule.Object["spec"].(map[string]interface{})["groups"].([]interface{})
+	require.Len(t, groups, 1)
+	ruleSpec := groups[0].(map[string]interface{})["rules"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "response_time_p95", ruleSpec["alert"])
+	assert.Equal(t, "5m", ruleSpec["for"])
+	assert.Equal(t, "webhook", ruleSpec["labels"].(map[string]interface{})["severity"])
+	assert.Equal(t, "Pod name {{ $labels.pod_name }}", ruleSpec["annotations"].(map[string]interface{})["descriptions"])
+
+	_, err = rule.MarshalJSON()
+	require.NoError(t, err)
+}
+
+func Test_newAlertmanagerConfig(t *testing.T) {
+	integration := prometheus.NewAlertManagerIntegration("keptn")
+
+	config, err := newAlertmanagerConfig("monitoring", map[string]string{"alertmanagerConfig": "keptn"}, integration)
+	require.NoError(t, err)
+
+	assert.Equal(t, "monitoring.coreos.com/v1alpha1", config.GetAPIVersion())
+	assert.Equal(t, "AlertmanagerConfig", config.GetKind())
+	assert.Equal(t, "monitoring", config.GetNamespace())
+	assert.Equal(t, "prometheus-service", config.GetName())
+	assert.Equal(t, map[string]string{"alertmanagerConfig": "keptn", "keptn.sh/managed": "true"}, config.GetLabels())
+
+	route := config.Object["spec"].(map[string]interface{})["route"].(map[string]interface{})
+	assert.Equal(t, "keptn_integration", route["receiver"])
+
+	_, err = config.MarshalJSON()
+	require.NoError(t, err)
+}
+
+func Test_getAlertManagerConfigMode(t *testing.T) {
+	defer func(original utils.EnvConfig) { env = original }(env)
+	env = utils.EnvConfig{AlertManagerConfigMode: "auto"}
+
+	fake := &k8stesting.Fake{}
+	operatorHelper := prometheus.NewOperatorHelper(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), &fakediscovery.FakeDiscovery{Fake: fake})
+	k := newTestKeptn(nil)
+
+	mode, _, err := getAlertManagerConfigMode(k, operatorHelper)
+	require.NoError(t, err)
+	assert.Equal(t, prometheusConfigModeConfigMap, mode)
+
+	fake.Resources = []*metav1.APIResourceList{
+		{GroupVersion: prometheus.AlertmanagerConfigGroupVersion, APIResources: []metav1.APIResource{{Name: "alertmanagerconfigs"}}},
+	}
+	mode, helper, err := getAlertManagerConfigMode(k, operatorHelper)
+	require.NoError(t, err)
+	assert.Equal(t, prometheusConfigModeOperator, mode)
+	assert.Same(t, operatorHelper, helper)
+
+	env.AlertManagerConfigMode = "configmap"
+	mode, _, err = getAlertManagerConfigMode(k, operatorHelper)
+	require.NoError(t, err)
+	assert.Equal(t, prometheusConfigModeConfigMap, mode)
+
+	env.AlertManagerConfigMode = "git"
+	_, _, err = getAlertManagerConfigMode(k, operatorHelper)
+	assert.Error(t, err)
+}
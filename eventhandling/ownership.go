@@ -0,0 +1,132 @@
+package eventhandling
+
+import (
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	prometheus_model "github.com/prometheus/common/model"
+)
+
+// managedLabel marks the scrape jobs and alerting rules generated by prometheus-service
+const managedLabel = "keptn_managed"
+
+// projectLabel and stageLabel identify the project and stage of generated scrape jobs, since they cannot be derived
+// from the job name or the namespace <project>-<stage> if the project contains a dash
+const (
+	projectLabel = "keptn_project"
+	stageLabel   = "keptn_stage"
+)
+
+const overwriteUnmanagedConfigEnvName = "OVERWRITE_UNMANAGED_CONFIG"
+
+// overwriteUnmanagedConfig returns true if scrape jobs and alerting groups that have not been generated by
+// prometheus-service may be replaced by generated ones with the same name
+func overwriteUnmanagedConfig() bool {
+	return utils.EnvVarOrDefault(overwriteUnmanagedConfigEnvName, "false") == "true"
+}
+
+// isManagedScrapeConfig returns true if the scrape job carries the managed label or has exactly the structure of a job
+// created by a version of prometheus-service that did not set the label yet
+func isManagedScrapeConfig(scrapeConfig *prometheus.ScrapeConfig) bool {
+	for _, staticConfig := range scrapeConfig.StaticConfigs {
+		if staticConfig.Labels[managedLabel] == "true" {
+			return true
+		}
+	}
+	for _, relabelConfig := range scrapeConfig.RelabelConfigs {
+		if relabelConfig != nil && (*relabelConfig)["target_label"] == managedLabel && (*relabelConfig)["replacement"] == "true" {
+			return true
+		}
+	}
+
+	service, namespace, suffix, ok := parseScrapeJob(scrapeConfig)
+	if !ok || len(scrapeConfig.StaticConfigs) != 1 || len(scrapeConfig.StaticConfigs[0].Targets) != 1 {
+		return false
+	}
+	return scrapeConfig.StaticConfigs[0].Targets[0] == service+suffix+"."+namespace+":80"
+}
+
+// isManagedAlertingGroup returns true if the alerting group is empty or contains a generated alerting rule
+func isManagedAlertingGroup(group *alertingGroup) bool {
+	if len(group.Rules) == 0 {
+		return true
+	}
+	for _, rule := range group.Rules {
+		if isManagedAlertingRule(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// isManagedAlertingRule returns true if the alerting rule carries the managed label or the labels of a rule created by
+// a version of prometheus-service that did not set the managed label yet
+func isManagedAlertingRule(rule *alertingRule) bool {
+	if rule.Labels == nil {
+		return false
+	}
+	if rule.Labels.KeptnManaged == "true" {
+		return true
+	}
+	return rule.Labels.Project != "" && rule.Labels.Stage != "" && rule.Labels.Service != ""
+}
+
+// managedStaticConfigLabels returns the labels of the static configs of generated scrape jobs
+func managedStaticConfigLabels(project string, stage string) prometheus_model.LabelSet {
+	return prometheus_model.LabelSet{
+		managedLabel: "true",
+		projectLabel: prometheus_model.LabelValue(project),
+		stageLabel:   prometheus_model.LabelValue(stage),
+	}
+}
+
+// managedRelabelConfigs returns the relabel configs attaching the managed, project and stage labels to the targets of
+// generated scrape jobs using kubernetes_sd_configs
+func managedRelabelConfigs(project string, stage string) []*prometheus.UntypedElement {
+	var relabelConfigs []*prometheus.UntypedElement
+	for _, label := range []struct{ name, value string }{{managedLabel, "true"}, {projectLabel, project}, {stageLabel, stage}} {
+		relabelConfigs = append(relabelConfigs, &prometheus.UntypedElement{
+			"target_label": label.name,
+			"replacement":  label.value,
+			"action":       "replace",
+		})
+	}
+	return relabelConfigs
+}
+
+// getScrapeJobProjectAndStage returns the project and stage labels of a generated scrape job, which are empty for jobs
+// created by a version of prometheus-service that did not set them yet
+func getScrapeJobProjectAndStage(scrapeConfig *prometheus.ScrapeConfig) (string, string) {
+	for _, staticConfig := range scrapeConfig.StaticConfigs {
+		if project, stage := staticConfig.Labels[projectLabel], staticConfig.Labels[stageLabel]; project != "" && stage != "" {
+			return string(project), string(stage)
+		}
+	}
+
+	var project, stage string
+	for _, relabelConfig := range scrapeConfig.RelabelConfigs {
+		if relabelConfig == nil {
+			continue
+		}
+		replacement, _ := (*relabelConfig)["replacement"].(string)
+		switch (*relabelConfig)["target_label"] {
+		case projectLabel:
+			project = replacement
+		case stageLabel:
+			stage = replacement
+		}
+	}
+	if project == "" || stage == "" {
+		return "", ""
+	}
+	return project, stage
+}
+
+// getAlertingGroupProjectAndStage returns the project and stage labels of the rules of a generated alerting group
+func getAlertingGroupProjectAndStage(group *alertingGroup) (string, string) {
+	for _, rule := range group.Rules {
+		if rule.Labels != nil && rule.Labels.Project != "" && rule.Labels.Stage != "" {
+			return rule.Labels.Project, rule.Labels.Stage
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,69 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+func Test_createScrapeJobConfigSetsManagedLabel(t *testing.T) {
+	config := &prometheus.Config{}
+
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, false, testScrapeSettings, scrapeTargetDiscoveryStatic))
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", true, false, testScrapeSettings, scrapeTargetDiscoveryEndpoints))
+
+	require.Len(t, config.ScrapeConfigs, 2)
+	assert.Equal(t, "true", string(config.ScrapeConfigs[0].StaticConfigs[0].Labels[managedLabel]))
+	assert.True(t, isManagedScrapeConfig(config.ScrapeConfigs[0]))
+	assert.True(t, isManagedScrapeConfig(config.ScrapeConfigs[1]))
+}
+
+func Test_createScrapeJobConfigUnmanagedJob(t *testing.T) {
+	config, err := prometheus.LoadYamlConfiguration(`
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    metrics_path: /custom
+    static_configs:
+      - targets: ['carts.sockshop-dev:8080']
+`)
+	require.NoError(t, err)
+	require.False(t, isManagedScrapeConfig(config.ScrapeConfigs[0]))
+
+	err = createScrapeJobConfig(config, "sockshop", "dev", "carts", false, false, testScrapeSettings, scrapeTargetDiscoveryStatic)
+	require.Error(t, err)
+	assert.Equal(t, "/custom", config.ScrapeConfigs[0].MetricsPath)
+
+	t.Setenv(overwriteUnmanagedConfigEnvName, "true")
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, false, testScrapeSettings, scrapeTargetDiscoveryStatic))
+	require.Len(t, config.ScrapeConfigs, 1)
+	assert.Equal(t, []string{"carts.sockshop-dev:80"}, config.ScrapeConfigs[0].StaticConfigs[0].Targets)
+	assert.True(t, isManagedScrapeConfig(config.ScrapeConfigs[0]))
+}
+
+func Test_isManagedScrapeConfigLegacyJob(t *testing.T) {
+	config, err := prometheus.LoadYamlConfiguration(`
+scrape_configs:
+  - job_name: carts-sockshop-dev-primary
+    static_configs:
+      - targets: ['carts-primary.sockshop-dev:80']
+`)
+	require.NoError(t, err)
+
+	assert.True(t, isManagedScrapeConfig(config.ScrapeConfigs[0]))
+}
+
+func Test_isManagedAlertingGroup(t *testing.T) {
+	assert.True(t, isManagedAlertingGroup(&alertingGroup{Name: "carts sockshop-dev alerts"}))
+	assert.True(t, isManagedAlertingGroup(&alertingGroup{
+		Rules: []*alertingRule{{Alert: "response_time_p95", Labels: &alertingLabel{KeptnManaged: "true"}}},
+	}))
+	assert.True(t, isManagedAlertingGroup(&alertingGroup{
+		Rules: []*alertingRule{{Alert: "response_time_p95", Labels: &alertingLabel{Project: "sockshop", Stage: "dev", Service: "carts"}}},
+	}))
+	assert.False(t, isManagedAlertingGroup(&alertingGroup{
+		Rules: []*alertingRule{{Alert: "HighLatency", Labels: &alertingLabel{Severity: "page"}}},
+	}))
+}
@@ -0,0 +1,181 @@
+package eventhandling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	prometheus_model "github.com/prometheus/common/model"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+const sliRecordingWindowsEnvName = "SLI_RECORDING_WINDOWS"
+
+// sliRecordingRulePrefix is the prefix of the recording rules generated for SLIs
+const sliRecordingRulePrefix = "keptn_sli:"
+
+// recordingDeploymentType is the deployment type the recorded SLI queries are rendered for
+const recordingDeploymentType = "primary"
+
+// getSLIRecordingWindows returns the windows configured by SLI_RECORDING_WINDOWS, e.g. 5m,1h
+func getSLIRecordingWindows() ([]prometheus_model.Duration, error) {
+	var windows []prometheus_model.Duration
+	for _, value := range strings.Split(utils.EnvVarOrDefault(sliRecordingWindowsEnvName, ""), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		window, err := prometheus_model.ParseDuration(value)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid window %q in %s", value, sliRecordingWindowsEnvName)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// getSLIRecordingGroupName returns the name of the group containing the SLI recording rules of the service
+func getSLIRecordingGroupName(service string, project string, stage string) string {
+	return service + " " + project + "-" + stage + " recordings"
+}
+
+// getSLIRecordingRuleName returns the name of the recording rule of the SLI evaluated over the window
+func getSLIRecordingRuleName(sli string, window prometheus_model.Duration) string {
+	return sliRecordingRulePrefix + invalidMetricNameChars.ReplaceAllString(sli, "_") + ":" + window.String()
+}
+
+// newRecordingHandler returns a handler rendering the SLI queries of the service the way they are recorded
+func newRecordingHandler(project string, stage string, service string, customQueries map[string]string) *prometheus.Handler {
+	handler := prometheus.NewPrometheusHandler(
+		"",
+		&keptnv2.EventData{
+			Project: project,
+			Stage:   stage,
+			Service: service,
+		},
+		recordingDeploymentType,
+		nil,
+		nil,
+	)
+	handler.CustomQueries = customQueries
+	return handler
+}
+
+// getRecordedQueries returns the queries of the SLIs rendered for each window and the names of their recording rules.
+// Only SLIs whose query depends on $DURATION_SECONDS are recorded. SLIs depending on placeholders that are only known
+// when the SLI is retrieved (e.g., $LABEL.<name> or custom filters) are skipped.
+func getRecordedQueries(handler *prometheus.Handler, windows []prometheus_model.Duration) map[string]string {
+	recordedQueries := map[string]string{}
+
+	for sli, query := range handler.CustomQueries {
+		if !strings.Contains(query, "$DURATION_SECONDS") {
+			continue
+		}
+
+		for _, window := range windows {
+			end := time.Now()
+			expr, err := handler.GetMetricQuery(sli, end.Add(-time.Duration(window)), end)
+			if err != nil || expr == "" || strings.Contains(expr, "$") {
+				continue
+			}
+			recordedQueries[expr] = getSLIRecordingRuleName(sli, window)
+		}
+	}
+
+	return recordedQueries
+}
+
+// getRecordedSeriesSelectors returns the recorded queries of the SLIs mapped to the selectors of the series recorded
+// for the service, which are used by Handler.GetMetricQuery instead of the queries. The recording rules are only
+// deployed to the default Prometheus instance, hence no selectors are returned for any other datasource.
+func getRecordedSeriesSelectors(
+	datasource *prometheus.Datasource, project string, stage string, service string, customQueries map[string]string,
+) (map[string]string, error) {
+	windows, err := getSLIRecordingWindows()
+	if err != nil || len(windows) == 0 || !isDefaultDatasource(datasource) {
+		return nil, err
+	}
+
+	selectors := map[string]string{}
+	selector := fmt.Sprintf(`{project="%s",stage="%s",service="%s"}`, project, stage, service)
+	for query, record := range getRecordedQueries(newRecordingHandler(project, stage, service, customQueries), windows) {
+		selectors[query] = record + selector
+	}
+	return selectors, nil
+}
+
+// createSLIRecordingRules creates or updates the group of recording rules of the SLIs in the sli.yaml of the service
+// for the windows configured by SLI_RECORDING_WINDOWS
+func createSLIRecordingRules(
+	k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData, stage keptnv2.Stage, alertingRulesConfig alertingRules,
+) (alertingRules, error) {
+	windows, err := getSLIRecordingWindows()
+	if err != nil {
+		return alertingRulesConfig, err
+	}
+	if len(windows) == 0 {
+		return alertingRulesConfig, nil
+	}
+
+	customQueries, err := getCustomQueries(k.GetResourceHandler(), eventData.Project, stage.Name, eventData.Service)
+	if err != nil {
+		return alertingRulesConfig, err
+	}
+
+	var rules []*alertingRule
+	for query, record := range getRecordedQueries(newRecordingHandler(eventData.Project, stage.Name, eventData.Service, customQueries), windows) {
+		rules = append(rules, &alertingRule{
+			Record: record,
+			Expr:   query,
+			Labels: &alertingLabel{
+				Service:      eventData.Service,
+				Project:      eventData.Project,
+				Stage:        stage.Name,
+				KeptnManaged: "true",
+			},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Record < rules[j].Record
+	})
+
+	groupName := getSLIRecordingGroupName(eventData.Service, eventData.Project, stage.Name)
+	group := getAlertingGroup(&alertingRulesConfig, groupName)
+	if group != nil && !isManagedAlertingGroup(group) && !overwriteUnmanagedConfig() {
+		return alertingRulesConfig, fmt.Errorf("alerting group %s has not been created by %s and is not overwritten, set %s to true to overwrite it", groupName, utils.ServiceName, overwriteUnmanagedConfigEnvName)
+	}
+
+	if len(rules) == 0 {
+		k.Logger().Infof("No SLI queries of service %s in stage %s depend on $DURATION_SECONDS, no recording rules created", eventData.Service, stage.Name)
+		if group != nil {
+			removeAlertingGroup(&alertingRulesConfig, groupName)
+		}
+		return alertingRulesConfig, nil
+	}
+
+	if group == nil {
+		group = &alertingGroup{Name: groupName}
+		alertingRulesConfig.Groups = append(alertingRulesConfig.Groups, group)
+	}
+	group.Rules = rules
+
+	return alertingRulesConfig, nil
+}
+
+// removeAlertingGroup removes the group with the given name
+func removeAlertingGroup(alertingRulesConfig *alertingRules, groupName string) {
+	var groups []*alertingGroup
+	for _, group := range alertingRulesConfig.Groups {
+		if group.Name != groupName {
+			groups = append(groups, group)
+		}
+	}
+	alertingRulesConfig.Groups = groups
+}
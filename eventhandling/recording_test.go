@@ -0,0 +1,120 @@
+package eventhandling
+
+import (
+	"testing"
+	"time"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	prometheus_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+const testSLI = `spec_version: "1.0"
+indicators:
+  error_rate: sum(rate(http_requests_total{job="$SERVICE-$PROJECT-$STAGE-$DEPLOYMENT",status!~'2..'}[$DURATION_SECONDS]))
+  pods: count(up{job="$SERVICE-$PROJECT-$STAGE"})
+`
+
+func Test_getSLIRecordingWindows(t *testing.T) {
+	windows, err := getSLIRecordingWindows()
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	t.Setenv(sliRecordingWindowsEnvName, "5m, 1h")
+	windows, err = getSLIRecordingWindows()
+	require.NoError(t, err)
+	assert.Equal(t, []prometheus_model.Duration{prometheus_model.Duration(5 * time.Minute), prometheus_model.Duration(time.Hour)}, windows)
+
+	t.Setenv(sliRecordingWindowsEnvName, "5x")
+	_, err = getSLIRecordingWindows()
+	assert.Error(t, err)
+}
+
+func Test_createSLIRecordingRules(t *testing.T) {
+	t.Setenv(sliRecordingWindowsEnvName, "5m,1h")
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/stage/dev/service/carts/resource/prometheus%2Fsli.yaml": testSLI,
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+
+	config, err := createSLIRecordingRules(k, eventData, keptnv2.Stage{Name: "dev"}, alertingRules{})
+	require.NoError(t, err)
+
+	require.Len(t, config.Groups, 1)
+	group := config.Groups[0]
+	assert.Equal(t, "carts sockshop-dev recordings", group.Name)
+
+	// the query without $DURATION_SECONDS is not recorded
+	require.Len(t, group.Rules, 2)
+	assert.Equal(t, "keptn_sli:error_rate:1h", group.Rules[0].Record)
+	assert.Equal(t, `sum(rate(http_requests_total{job="carts-sockshop-dev-primary",status!~'2..'}[3600s]))`, group.Rules[0].Expr)
+	assert.Equal(t, "keptn_sli:error_rate:5m", group.Rules[1].Record)
+	assert.Equal(t, "true", group.Rules[1].Labels.KeptnManaged)
+
+	service, namespace, ok := parseAlertingGroupName(group.Name)
+	assert.True(t, ok)
+	assert.Equal(t, "carts", service)
+	assert.Equal(t, "sockshop-dev", namespace)
+}
+
+func Test_createSLIRecordingRulesDisabled(t *testing.T) {
+	k := newTestKeptn(map[string]string{
+		"/v1/project/sockshop/stage/dev/service/carts/resource/prometheus%2Fsli.yaml": testSLI,
+	})
+	eventData := keptnevents.ConfigureMonitoringEventData{Project: "sockshop", Service: "carts"}
+
+	config, err := createSLIRecordingRules(k, eventData, keptnv2.Stage{Name: "dev"}, alertingRules{})
+	require.NoError(t, err)
+	assert.Empty(t, config.Groups)
+}
+
+func Test_getRecordedSeriesSelectors(t *testing.T) {
+	t.Setenv(sliRecordingWindowsEnvName, "5m")
+	customQueries := map[string]string{
+		"error_rate": `sum(rate(http_requests_total{job="$SERVICE-$PROJECT-$STAGE-$DEPLOYMENT"}[$DURATION_SECONDS]))`,
+	}
+
+	datasource := &prometheus.Datasource{URL: env.PrometheusEndpoint, Type: prometheus.DatasourceTypePrometheus}
+
+	selectors, err := getRecordedSeriesSelectors(datasource, "sockshop", "dev", "carts", customQueries)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		`sum(rate(http_requests_total{job="carts-sockshop-dev-primary"}[300s]))`: `keptn_sli:error_rate:5m{project="sockshop",stage="dev",service="carts"}`,
+	}, selectors)
+}
+
+func Test_getRecordedSeriesSelectorsOfOtherDatasources(t *testing.T) {
+	t.Setenv(sliRecordingWindowsEnvName, "5m")
+	customQueries := map[string]string{
+		"error_rate": `sum(rate(http_requests_total{job="$SERVICE-$PROJECT-$STAGE-$DEPLOYMENT"}[$DURATION_SECONDS]))`,
+	}
+
+	// the recording rules are not deployed to project specific Prometheus instances
+	datasource := &prometheus.Datasource{URL: "http://prometheus.sockshop.svc:9090", Type: prometheus.DatasourceTypePrometheus}
+	selectors, err := getRecordedSeriesSelectors(datasource, "sockshop", "dev", "carts", customQueries)
+	require.NoError(t, err)
+	assert.Empty(t, selectors)
+
+	// nor to Prometheus compatible backends
+	datasource = &prometheus.Datasource{URL: env.PrometheusEndpoint, Type: prometheus.DatasourceTypeThanos}
+	selectors, err = getRecordedSeriesSelectors(datasource, "sockshop", "dev", "carts", customQueries)
+	require.NoError(t, err)
+	assert.Empty(t, selectors)
+}
+
+func Test_getRecordedQueriesWithUnresolvedPlaceholders(t *testing.T) {
+	customQueries := map[string]string{
+		"error_rate": `sum(rate(http_requests_total{job="$SERVICE-$PROJECT-$STAGE"}[$DURATION_SECONDS]))`,
+		"labeled":    `sum(rate(http_requests_total{version="$LABEL.version"}[$DURATION_SECONDS]))`,
+		"filtered":   `sum(rate(http_requests_total{handler="$handler"}[$DURATION_SECONDS]))`,
+	}
+
+	queries := getRecordedQueries(newRecordingHandler("sockshop", "dev", "carts", customQueries), []prometheus_model.Duration{prometheus_model.Duration(5 * time.Minute)})
+	assert.Equal(t, map[string]string{
+		`sum(rate(http_requests_total{job="carts-sockshop-dev"}[300s]))`: "keptn_sli:error_rate:5m",
+	}, queries)
+}
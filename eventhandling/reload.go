@@ -0,0 +1,112 @@
+package eventhandling
+
+import (
+	"fmt"
+	"time"
+
+	keptnevents "github.com/keptn/go-utils/pkg/lib"
+	"github.com/keptn/go-utils/pkg/sdk"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+)
+
+const reloadConfigEnvName = "RELOAD_CONFIG"
+const reloadTimeoutEnvName = "RELOAD_TIMEOUT"
+
+// configurationResult describes whether Prometheus and Alertmanager are running the updated configuration
+type configurationResult struct {
+	// messages describe the verified state of Prometheus and Alertmanager
+	messages []string
+	// unverified is set if a reload has been requested but the updated configuration could not be verified
+	unverified bool
+	// diffs contains the changes of the configuration files in a dry-run
+	diffs []string
+	// reloads contains the reloads of the updated components, which are run after releasing the prometheusConfigMutex
+	// since waiting for the updated configuration can take up to RELOAD_TIMEOUT
+	reloads []componentReload
+}
+
+// componentReload reloads a component and verifies that it runs the updated configuration
+type componentReload struct {
+	component string
+	reload    func() error
+}
+
+func (r *configurationResult) addDiff(diff string) {
+	if diff != "" {
+		r.diffs = append(r.diffs, diff)
+	}
+}
+
+func (r *configurationResult) add(component string, err error) {
+	if err != nil {
+		r.messages = append(r.messages, fmt.Sprintf("%s configuration not verified: %v", component, err))
+		r.unverified = true
+		return
+	}
+	r.messages = append(r.messages, component+" configuration reloaded and verified")
+}
+
+func (r *configurationResult) addReload(component string, reload func() error) {
+	r.reloads = append(r.reloads, componentReload{component: component, reload: reload})
+}
+
+// reload runs the pending reloads and adds their results
+func (r *configurationResult) reload() {
+	for _, reload := range r.reloads {
+		r.add(reload.component, reload.reload())
+	}
+	r.reloads = nil
+}
+
+// reloadConfigEnabled returns true if Prometheus and Alertmanager should be reloaded after updating their configmaps
+func reloadConfigEnabled() bool {
+	return utils.EnvVarOrDefault(reloadConfigEnvName, "false") == "true"
+}
+
+// getConfigReloader returns a reloader waiting for the time configured by RELOAD_TIMEOUT
+func getConfigReloader(k sdk.IKeptn) *prometheus.ConfigReloader {
+	timeout, err := time.ParseDuration(utils.EnvVarOrDefault(reloadTimeoutEnvName, "2m"))
+	if err != nil {
+		k.Logger().Errorf("Error while converting %s value. Using default value instead!", reloadTimeoutEnvName)
+		timeout = 2 * time.Minute
+	}
+	return prometheus.NewConfigReloader(timeout)
+}
+
+// reloadPrometheus reloads Prometheus until the scrape jobs of the service are active
+func reloadPrometheus(k sdk.IKeptn, eventData keptnevents.ConfigureMonitoringEventData) error {
+	if env.PrometheusEndpoint == "" {
+		return fmt.Errorf("PROMETHEUS_ENDPOINT is not set")
+	}
+
+	scope := api.NewResourceScope()
+	scope.Project(eventData.Project)
+	scope.Resource("shipyard.yaml")
+
+	shipyard, err := GetShipyard(k.GetResourceHandler(), *scope)
+	if err != nil {
+		return err
+	}
+
+	var jobNames []string
+	for _, stage := range shipyard.Spec.Stages {
+		jobName := eventData.Service + "-" + eventData.Project + "-" + stage.Name
+		jobNames = append(jobNames, jobName+"-primary", jobName+"-canary", jobName)
+	}
+
+	k.Logger().Infof("Reloading Prometheus at %s", env.PrometheusEndpoint)
+	return getConfigReloader(k).ReloadPrometheus(env.PrometheusEndpoint, jobNames)
+}
+
+// reloadAlertManager reloads Alertmanager until the receiver forwarding alerts to prometheus-service is active
+func reloadAlertManager(k sdk.IKeptn) error {
+	if env.AlertManagerEndpoint == "" {
+		return fmt.Errorf("ALERT_MANAGER_ENDPOINT is not set")
+	}
+
+	k.Logger().Infof("Reloading Alertmanager at %s", env.AlertManagerEndpoint)
+	return getConfigReloader(k).ReloadAlertManager(env.AlertManagerEndpoint, env.AlertManagerReceiver)
+}
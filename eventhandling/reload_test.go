@@ -0,0 +1,45 @@
+package eventhandling
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_configurationResult(t *testing.T) {
+	result := configurationResult{}
+
+	result.add("Prometheus", nil)
+	assert.False(t, result.unverified)
+
+	result.add("Alertmanager", errors.New("reload failed: 403 Forbidden"))
+	assert.True(t, result.unverified)
+	assert.Equal(t, []string{
+		"Prometheus configuration reloaded and verified",
+		"Alertmanager configuration not verified: reload failed: 403 Forbidden",
+	}, result.messages)
+}
+
+func Test_configurationResultReload(t *testing.T) {
+	result := configurationResult{}
+
+	reloaded := false
+	result.addReload("Prometheus", func() error {
+		reloaded = true
+		return nil
+	})
+	result.addReload("Alertmanager", func() error { return errors.New("timeout") })
+
+	// the reloads are deferred until the configuration has been updated
+	assert.False(t, reloaded)
+	assert.Empty(t, result.messages)
+
+	result.reload()
+	assert.True(t, reloaded)
+	assert.True(t, result.unverified)
+	assert.Equal(t, []string{
+		"Prometheus configuration reloaded and verified",
+		"Alertmanager configuration not verified: timeout",
+	}, result.messages)
+}
@@ -0,0 +1,56 @@
+package eventhandling
+
+import (
+	"strings"
+
+	api "github.com/keptn/go-utils/pkg/api/utils"
+	"github.com/keptn/go-utils/pkg/sdk"
+)
+
+// getResourceContents returns the content of a resource on project, stage and service level (in this order), so that
+// callers can let the configuration of a more specific level override the one of a less specific level.
+// Levels on which the resource does not exist are skipped.
+func getResourceContents(resourceHandler sdk.ResourceHandler, project string, stage string, service string, resourceURI string) ([]string, error) {
+	var scopes []*api.ResourceScope
+
+	if project != "" {
+		scope := api.NewResourceScope()
+		scope.Project(project)
+		scope.Resource(resourceURI)
+		scopes = append(scopes, scope)
+	}
+
+	if project != "" && stage != "" {
+		scope := api.NewResourceScope()
+		scope.Project(project)
+		scope.Stage(stage)
+		scope.Resource(resourceURI)
+		scopes = append(scopes, scope)
+	}
+
+	if project != "" && stage != "" && service != "" {
+		scope := api.NewResourceScope()
+		scope.Project(project)
+		scope.Stage(stage)
+		scope.Service(service)
+		scope.Resource(resourceURI)
+		scopes = append(scopes, scope)
+	}
+
+	var contents []string
+	for _, scope := range scopes {
+		res, err := resourceHandler.GetResource(*scope)
+		if err != nil {
+			// return error except "resource not found" type
+			if !strings.Contains(strings.ToLower(err.Error()), "resource not found") {
+				return nil, err
+			}
+			continue
+		}
+		if res != nil && res.ResourceContent != "" {
+			contents = append(contents, res.ResourceContent)
+		}
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,53 @@
+package eventhandling
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResourceHandler returns the resources stored for the "project/stage/service/uri" path of the scope
+type fakeResourceHandler struct {
+	resources map[string]string
+}
+
+func (f fakeResourceHandler) GetResource(scope api.ResourceScope, _ ...api.URIOption) (*models.Resource, error) {
+	content, ok := f.resources[scope.GetProjectPath()+scope.GetStagePath()+scope.GetServicePath()+scope.GetResourcePath()]
+	if !ok {
+		return nil, api.ResourceNotFoundError
+	}
+	return &models.Resource{ResourceContent: content}, nil
+}
+
+func Test_getResourceContents(t *testing.T) {
+	resourceHandler := fakeResourceHandler{
+		resources: map[string]string{
+			"/v1/project/sockshop/resource/prometheus%2Fsli.yaml":                             "project",
+			"/v1/project/sockshop/stage/staging/service/carts/resource/prometheus%2Fsli.yaml": "service",
+		},
+	}
+
+	contents, err := getResourceContents(resourceHandler, "sockshop", "staging", "carts", "prometheus/sli.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project", "service"}, contents)
+
+	contents, err = getResourceContents(resourceHandler, "sockshop", "", "", "prometheus/sli.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project"}, contents)
+}
+
+func Test_getResourceContentsWithError(t *testing.T) {
+	_, err := getResourceContents(erroneousResourceHandler{}, "sockshop", "staging", "carts", "prometheus/sli.yaml")
+	require.Error(t, err)
+}
+
+// erroneousResourceHandler fails to retrieve any resource
+type erroneousResourceHandler struct{}
+
+func (erroneousResourceHandler) GetResource(_ api.ResourceScope, _ ...api.URIOption) (*models.Resource, error) {
+	return nil, errors.New("connection refused")
+}
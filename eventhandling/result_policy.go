@@ -0,0 +1,140 @@
+package eventhandling
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	"gopkg.in/yaml.v2"
+)
+
+const resultPolicyEnvName = "SLI_RESULT_POLICY"
+const resultPolicyMinSuccessPercentageEnvName = "SLI_RESULT_MIN_SUCCESS_PERCENTAGE"
+
+// resultPolicyDefault sets the result to warning if any indicator failed and to fail if all indicators failed
+const resultPolicyDefault = "default"
+
+// resultPolicyKeySLI sets the result to fail if any indicator marked as key_sli in the slo.yaml failed
+const resultPolicyKeySLI = "key_sli"
+
+// resultPolicyMinSuccessPercentage sets the result to pass if at least the configured percentage of indicators succeeded
+const resultPolicyMinSuccessPercentage = "min_success_percentage"
+
+// resultPolicyAlwaysPass always sets the result to pass and leaves the evaluation of the indicators to lighthouse
+const resultPolicyAlwaysPass = "always_pass"
+
+// resultPolicy defines how the results of the single indicators are aggregated to the result of the get-sli.finished
+// event. It can be configured in the sli.yaml, e.g.:
+//
+//	result_policy:
+//	  policy: min_success_percentage
+//	  min_success_percentage: 80
+type resultPolicy struct {
+	Policy               string   `yaml:"policy,omitempty"`
+	MinSuccessPercentage *float64 `yaml:"min_success_percentage,omitempty"`
+}
+
+// getResultPolicy returns the result policy defined by the environment, overridden by the result_policy field of the
+// sli.yaml on project, stage and service level
+func getResultPolicy(resourceHandler sdk.ResourceHandler, project string, stage string, service string) (resultPolicy, error) {
+	policy := resultPolicy{
+		Policy: utils.EnvVarOrDefault(resultPolicyEnvName, resultPolicyDefault),
+	}
+
+	if value := utils.EnvVarOrDefault(resultPolicyMinSuccessPercentageEnvName, ""); value != "" {
+		percentage, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return policy, fmt.Errorf("invalid value for %s: %w", resultPolicyMinSuccessPercentageEnvName, err)
+		}
+		policy.MinSuccessPercentage = &percentage
+	}
+
+	contents, err := getResourceContents(resourceHandler, project, stage, service, utils.SliResourceURI)
+	if err != nil {
+		return policy, err
+	}
+
+	for _, content := range contents {
+		sliFile := struct {
+			ResultPolicy resultPolicy `yaml:"result_policy"`
+		}{}
+		if err := yaml.Unmarshal([]byte(content), &sliFile); err != nil {
+			return policy, err
+		}
+
+		if sliFile.ResultPolicy.Policy != "" {
+			policy.Policy = sliFile.ResultPolicy.Policy
+		}
+		if sliFile.ResultPolicy.MinSuccessPercentage != nil {
+			policy.MinSuccessPercentage = sliFile.ResultPolicy.MinSuccessPercentage
+		}
+	}
+
+	switch policy.Policy {
+	case resultPolicyDefault, resultPolicyKeySLI, resultPolicyAlwaysPass:
+	case resultPolicyMinSuccessPercentage:
+		if policy.MinSuccessPercentage == nil {
+			return policy, fmt.Errorf("result policy %s requires min_success_percentage to be set", policy.Policy)
+		}
+		if *policy.MinSuccessPercentage < 0 || *policy.MinSuccessPercentage > 100 {
+			return policy, fmt.Errorf("min_success_percentage %v of result policy %s is not between 0 and 100", *policy.MinSuccessPercentage, policy.Policy)
+		}
+	default:
+		return policy, fmt.Errorf("unknown result policy %s", policy.Policy)
+	}
+
+	return policy, nil
+}
+
+// aggregate computes the overall result of the given SLI results and returns it together with an explanation.
+// keySLIs contains the names of the indicators marked as key_sli and is only used by the key_sli policy.
+func (p resultPolicy) aggregate(sliResults []*keptnv2.SLIResult, keySLIs map[string]bool) (keptnv2.ResultType, string) {
+	var failed []string
+	for _, sliResult := range sliResults {
+		if !sliResult.Success {
+			failed = append(failed, sliResult.Metric)
+		}
+	}
+
+	if len(sliResults) == 0 {
+		return keptnv2.ResultPass, fmt.Sprintf("result policy %s: no indicators requested", p.Policy)
+	}
+
+	switch p.Policy {
+	case resultPolicyAlwaysPass:
+		return keptnv2.ResultPass, fmt.Sprintf("result policy %s: %d of %d indicators failed, evaluation is left to lighthouse", p.Policy, len(failed), len(sliResults))
+	case resultPolicyKeySLI:
+		var failedKeySLIs []string
+		for _, metric := range failed {
+			if keySLIs[metric] {
+				failedKeySLIs = append(failedKeySLIs, metric)
+			}
+		}
+		if len(failedKeySLIs) > 0 {
+			return keptnv2.ResultFailed, fmt.Sprintf("result policy %s: key SLIs failed: %s", p.Policy, strings.Join(failedKeySLIs, ", "))
+		}
+		if len(failed) > 0 {
+			return keptnv2.ResultWarning, fmt.Sprintf("result policy %s: no key SLI failed, but other indicators failed: %s", p.Policy, strings.Join(failed, ", "))
+		}
+		return keptnv2.ResultPass, fmt.Sprintf("result policy %s: all indicators succeeded", p.Policy)
+	case resultPolicyMinSuccessPercentage:
+		successPercentage := float64(len(sliResults)-len(failed)) / float64(len(sliResults)) * 100
+		if successPercentage >= *p.MinSuccessPercentage {
+			return keptnv2.ResultPass, fmt.Sprintf("result policy %s: %.0f%% of indicators succeeded (required: %.0f%%)", p.Policy, successPercentage, *p.MinSuccessPercentage)
+		}
+		return keptnv2.ResultFailed, fmt.Sprintf("result policy %s: only %.0f%% of indicators succeeded (required: %.0f%%), failed: %s", p.Policy, successPercentage, *p.MinSuccessPercentage, strings.Join(failed, ", "))
+	default:
+		// If we hand any problem retrieving an SLI value, we set the result of the overall .finished event
+		// to Warning, if all fail ResultFailed is set for the event
+		if len(failed) == len(sliResults) {
+			return keptnv2.ResultFailed, fmt.Sprintf("result policy %s: unable to retrieve metrics", p.Policy)
+		}
+		if len(failed) > 0 {
+			return keptnv2.ResultWarning, fmt.Sprintf("result policy %s: indicators failed: %s", p.Policy, strings.Join(failed, ", "))
+		}
+		return keptnv2.ResultPass, fmt.Sprintf("result policy %s: all indicators succeeded", p.Policy)
+	}
+}
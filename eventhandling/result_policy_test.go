@@ -0,0 +1,126 @@
+package eventhandling
+
+import (
+	"testing"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resultPolicy_aggregate(t *testing.T) {
+	eighty := 80.0
+	sliResults := []*keptnv2.SLIResult{
+		{Metric: "throughput", Success: true},
+		{Metric: "error_rate", Success: true},
+		{Metric: "response_time_p50", Success: true},
+		{Metric: "response_time_p90", Success: true},
+		{Metric: "response_time_p95", Success: false},
+	}
+
+	tests := []struct {
+		name       string
+		policy     resultPolicy
+		sliResults []*keptnv2.SLIResult
+		keySLIs    map[string]bool
+		want       keptnv2.ResultType
+	}{
+		{
+			name:       "default with one failed indicator",
+			policy:     resultPolicy{Policy: resultPolicyDefault},
+			sliResults: sliResults,
+			want:       keptnv2.ResultWarning,
+		},
+		{
+			name:       "default with all indicators failed",
+			policy:     resultPolicy{Policy: resultPolicyDefault},
+			sliResults: []*keptnv2.SLIResult{{Metric: "throughput", Success: false}},
+			want:       keptnv2.ResultFailed,
+		},
+		{
+			name:       "key_sli with failed key SLI",
+			policy:     resultPolicy{Policy: resultPolicyKeySLI},
+			sliResults: sliResults,
+			keySLIs:    map[string]bool{"response_time_p95": true},
+			want:       keptnv2.ResultFailed,
+		},
+		{
+			name:       "key_sli with failed non-key SLI",
+			policy:     resultPolicy{Policy: resultPolicyKeySLI},
+			sliResults: sliResults,
+			keySLIs:    map[string]bool{"throughput": true},
+			want:       keptnv2.ResultWarning,
+		},
+		{
+			name:       "min_success_percentage reached",
+			policy:     resultPolicy{Policy: resultPolicyMinSuccessPercentage, MinSuccessPercentage: &eighty},
+			sliResults: sliResults,
+			want:       keptnv2.ResultPass,
+		},
+		{
+			name:       "min_success_percentage not reached",
+			policy:     resultPolicy{Policy: resultPolicyMinSuccessPercentage, MinSuccessPercentage: &eighty},
+			sliResults: sliResults[3:],
+			want:       keptnv2.ResultFailed,
+		},
+		{
+			name:       "always_pass",
+			policy:     resultPolicy{Policy: resultPolicyAlwaysPass},
+			sliResults: []*keptnv2.SLIResult{{Metric: "throughput", Success: false}},
+			want:       keptnv2.ResultPass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, message := tt.policy.aggregate(tt.sliResults, tt.keySLIs)
+			assert.Equal(t, tt.want, result)
+			assert.Contains(t, message, tt.policy.Policy)
+		})
+	}
+}
+
+func Test_getResultPolicy(t *testing.T) {
+	t.Setenv(resultPolicyEnvName, resultPolicyKeySLI)
+
+	resourceHandler := fakeResourceHandler{
+		resources: map[string]string{
+			"/v1/project/sockshop/stage/staging/resource/prometheus%2Fsli.yaml": `
+spec_version: '1.0'
+result_policy:
+  policy: min_success_percentage
+  min_success_percentage: 75
+indicators:
+  throughput: sum(rate(http_requests_total[$DURATION_SECONDS]))
+`,
+		},
+	}
+
+	policy, err := getResultPolicy(resourceHandler, "sockshop", "dev", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, resultPolicyKeySLI, policy.Policy)
+
+	policy, err = getResultPolicy(resourceHandler, "sockshop", "staging", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, resultPolicyMinSuccessPercentage, policy.Policy)
+	require.NotNil(t, policy.MinSuccessPercentage)
+	assert.Equal(t, 75.0, *policy.MinSuccessPercentage)
+
+	t.Setenv(resultPolicyEnvName, "unknown")
+	_, err = getResultPolicy(resourceHandler, "sockshop", "dev", "carts")
+	require.Error(t, err)
+}
+
+func Test_getResultPolicyPercentageOutOfRange(t *testing.T) {
+	t.Setenv(resultPolicyEnvName, resultPolicyMinSuccessPercentage)
+	resourceHandler := fakeResourceHandler{resources: map[string]string{}}
+
+	for _, value := range []string{"150", "-10"} {
+		t.Setenv(resultPolicyMinSuccessPercentageEnvName, value)
+		_, err := getResultPolicy(resourceHandler, "sockshop", "dev", "carts")
+		assert.Error(t, err, value)
+	}
+
+	t.Setenv(resultPolicyMinSuccessPercentageEnvName, "100")
+	_, err := getResultPolicy(resourceHandler, "sockshop", "dev", "carts")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package eventhandling
+
+import (
+	"path"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// defaultAlertingRulesFileName is the key of the Prometheus configmap the generated alerting rules are written to if
+// no rule file of the configmap is loaded by Prometheus
+const defaultAlertingRulesFileName = "alerting_rules.yml"
+
+// defaultRuleFilesDir is the directory the Prometheus configmap is mounted to by the Prometheus Helm chart
+const defaultRuleFilesDir = "/etc/config"
+
+// getAlertingRulesFileName returns the key of the Prometheus configmap containing the alerting rules. This is the file
+// configured by PROMETHEUS_RULES_FILENAME or, if it is not set, the first rule file loaded by Prometheus that is part
+// of the configmap, preferring alerting_rules.yml.
+func getAlertingRulesFileName(config *prometheus.Config, data map[string]string) string {
+	if env.PrometheusRulesFileName != "" {
+		return env.PrometheusRulesFileName
+	}
+
+	if isRuleFileLoaded(config, defaultAlertingRulesFileName) {
+		return defaultAlertingRulesFileName
+	}
+
+	for _, ruleFile := range config.RuleFiles {
+		fileName := path.Base(ruleFile)
+		if _, ok := data[fileName]; ok && fileName != env.PrometheusConfigFileName {
+			return fileName
+		}
+	}
+
+	return defaultAlertingRulesFileName
+}
+
+// isRuleFileLoaded returns true if an entry of rule_files (which may contain a glob pattern) matches the configmap key
+func isRuleFileLoaded(config *prometheus.Config, fileName string) bool {
+	for _, ruleFile := range config.RuleFiles {
+		if matched, err := path.Match(path.Base(ruleFile), fileName); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureRuleFileLoaded adds the configmap key to rule_files if no entry matches it. The file is expected in the
+// directory of the existing entries or, if there are none, in the directory the Prometheus Helm chart mounts the
+// configmap to. Returns true if the entry has been added.
+func ensureRuleFileLoaded(config *prometheus.Config, fileName string) bool {
+	if isRuleFileLoaded(config, fileName) {
+		return false
+	}
+
+	dir := defaultRuleFilesDir
+	if len(config.RuleFiles) > 0 {
+		dir = path.Dir(config.RuleFiles[0])
+	}
+
+	config.RuleFiles = append(config.RuleFiles, path.Join(dir, fileName))
+	return true
+}
@@ -0,0 +1,45 @@
+package eventhandling
+
+import (
+	"testing"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getAlertingRulesFileName(t *testing.T) {
+	env.PrometheusConfigFileName = "prometheus.yml"
+	env.PrometheusRulesFileName = ""
+
+	// the rule file of the Prometheus Helm chart
+	config := &prometheus.Config{RuleFiles: []string{"/etc/config/recording_rules.yml", "/etc/config/alerting_rules.yml"}}
+	assert.Equal(t, "alerting_rules.yml", getAlertingRulesFileName(config, map[string]string{"alerting_rules.yml": ""}))
+
+	// a rule file of the configmap loaded by Prometheus
+	config = &prometheus.Config{RuleFiles: []string{"/etc/prometheus/prometheus.rules"}}
+	assert.Equal(t, "prometheus.rules", getAlertingRulesFileName(config, map[string]string{"prometheus.yml": "", "prometheus.rules": ""}))
+
+	// no rule file of the configmap is loaded
+	config = &prometheus.Config{RuleFiles: []string{"/etc/rules/*.yml"}}
+	assert.Equal(t, "alerting_rules.yml", getAlertingRulesFileName(config, map[string]string{"prometheus.yml": ""}))
+
+	env.PrometheusRulesFileName = "keptn.rules"
+	defer func() { env.PrometheusRulesFileName = "" }()
+	assert.Equal(t, "keptn.rules", getAlertingRulesFileName(config, map[string]string{"prometheus.yml": ""}))
+}
+
+func Test_ensureRuleFileLoaded(t *testing.T) {
+	config := &prometheus.Config{}
+	assert.True(t, ensureRuleFileLoaded(config, "alerting_rules.yml"))
+	assert.Equal(t, []string{"/etc/config/alerting_rules.yml"}, config.RuleFiles)
+	assert.False(t, ensureRuleFileLoaded(config, "alerting_rules.yml"))
+
+	// the directory of the existing entries is used
+	config = &prometheus.Config{RuleFiles: []string{"/etc/prometheus/recording.rules"}}
+	assert.True(t, ensureRuleFileLoaded(config, "alerting_rules.yml"))
+	assert.Equal(t, []string{"/etc/prometheus/recording.rules", "/etc/prometheus/alerting_rules.yml"}, config.RuleFiles)
+
+	// glob patterns match the file
+	config = &prometheus.Config{RuleFiles: []string{"/etc/config/*.yml"}}
+	assert.False(t, ensureRuleFileLoaded(config, "alerting_rules.yml"))
+}
@@ -0,0 +1,157 @@
+package eventhandling
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	"github.com/keptn/go-utils/pkg/sdk"
+	prometheus_model "github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+)
+
+// scrapeSettingsResourceURI holds the name of the resource that contains the settings of the generated scrape jobs
+const scrapeSettingsResourceURI = "prometheus/scrape.yaml"
+
+// defaultScrapeTimeout is the scrape timeout of the generated scrape jobs if none is configured
+const defaultScrapeTimeout = prometheus_model.Duration(3 * time.Second)
+
+// defaultScrapePort is the port of the kubernetes service scraped by static targets if none is configured
+const defaultScrapePort = 80
+
+// scrapeSettings holds the settings of the scrape jobs generated for a service, e.g.:
+//
+//	scrape_interval: 15s
+//	scrape_timeout: 10s
+//	metrics_path: /actuator/prometheus
+//	port: 8080
+//	scheme: https
+//	tls_config:
+//	  insecure_skip_verify: true
+//	basic_auth:
+//	  username: prometheus
+//	  password_file: /etc/prometheus/secrets/carts
+//	params:
+//	  format: [prometheus]
+//	relabel_configs:
+//	  - target_label: team
+//	    replacement: checkout
+type scrapeSettings struct {
+	ScrapeInterval prometheus_model.Duration    `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout  prometheus_model.Duration    `yaml:"scrape_timeout,omitempty"`
+	MetricsPath    string                       `yaml:"metrics_path,omitempty"`
+	Port           int                          `yaml:"port,omitempty"`
+	Scheme         string                       `yaml:"scheme,omitempty"`
+	TLSConfig      prometheus.UntypedElement    `yaml:"tls_config,omitempty"`
+	BasicAuth      prometheus.UntypedElement    `yaml:"basic_auth,omitempty"`
+	Params         url.Values                   `yaml:"params,omitempty"`
+	RelabelConfigs []*prometheus.UntypedElement `yaml:"relabel_configs,omitempty"`
+}
+
+// merge returns the settings overridden by the settings set in other
+func (s scrapeSettings) merge(other scrapeSettings) scrapeSettings {
+	if other.ScrapeInterval != 0 {
+		s.ScrapeInterval = other.ScrapeInterval
+	}
+	if other.ScrapeTimeout != 0 {
+		s.ScrapeTimeout = other.ScrapeTimeout
+	}
+	if other.MetricsPath != "" {
+		s.MetricsPath = other.MetricsPath
+	}
+	if other.Port != 0 {
+		s.Port = other.Port
+	}
+	if other.Scheme != "" {
+		s.Scheme = other.Scheme
+	}
+	if other.TLSConfig != nil {
+		s.TLSConfig = other.TLSConfig
+	}
+	if other.BasicAuth != nil {
+		s.BasicAuth = other.BasicAuth
+	}
+	if other.Params != nil {
+		s.Params = other.Params
+	}
+	if other.RelabelConfigs != nil {
+		s.RelabelConfigs = other.RelabelConfigs
+	}
+	return s
+}
+
+// scrapeTimeout returns the configured scrape timeout or the default timeout, which is limited to the scrape interval
+func (s scrapeSettings) scrapeTimeout() prometheus_model.Duration {
+	if s.ScrapeTimeout != 0 {
+		return s.ScrapeTimeout
+	}
+	if s.ScrapeInterval != 0 && s.ScrapeInterval < defaultScrapeTimeout {
+		return s.ScrapeInterval
+	}
+	return defaultScrapeTimeout
+}
+
+// getDefaultScrapeSettings returns the scrape settings configured by the SCRAPE_INTERVAL and METRICS_SCRAPE_PATH env
+// vars
+func getDefaultScrapeSettings(k sdk.IKeptn) scrapeSettings {
+	return scrapeSettings{
+		ScrapeInterval: prometheus_model.Duration(getScrapeInterval(k)),
+		MetricsPath:    utils.EnvVarOrDefault(metricsScrapePathEnvName, "/metrics"),
+	}
+}
+
+// getScrapeSettings retrieves the scrape settings of the service considering the configuration on project, stage and
+// service level, where the configuration of a more specific level overrides the one of a less specific level and the
+// given defaults
+func getScrapeSettings(resourceHandler sdk.ResourceHandler, project string, stage string, service string, defaults scrapeSettings) (scrapeSettings, error) {
+	contents, err := getResourceContents(resourceHandler, project, stage, service, scrapeSettingsResourceURI)
+	if err != nil {
+		return defaults, err
+	}
+
+	result := defaults
+	for _, content := range contents {
+		settings := scrapeSettings{}
+		if err := yaml.UnmarshalStrict([]byte(content), &settings); err != nil {
+			return defaults, fmt.Errorf("unable to parse %s: %w", scrapeSettingsResourceURI, err)
+		}
+		result = result.merge(settings)
+	}
+
+	if result.Port < 0 || result.Port > 65535 {
+		return defaults, fmt.Errorf("invalid port %d in %s", result.Port, scrapeSettingsResourceURI)
+	}
+	return result, nil
+}
+
+// getStageScrapeSettings returns the scrape settings of the service in each stage of the shipyard
+func getStageScrapeSettings(k sdk.IKeptn, shipyard *keptnv2.Shipyard, project string, service string) (map[string]scrapeSettings, error) {
+	defaults := getDefaultScrapeSettings(k)
+
+	result := map[string]scrapeSettings{}
+	for _, stage := range shipyard.Spec.Stages {
+		settings, err := getScrapeSettings(k.GetResourceHandler(), project, stage.Name, service, defaults)
+		if err != nil {
+			return nil, err
+		}
+		result[stage.Name] = settings
+	}
+	return result, nil
+}
+
+// setScrapeConfigElement sets or, if the value is empty, removes a setting of the scrape config that is not part of
+// the ScrapeConfig struct
+func setScrapeConfigElement(scrapeConfig *prometheus.ScrapeConfig, key string, value prometheus.UntypedElement) {
+	if len(value) == 0 {
+		delete(scrapeConfig.RemainingElements, key)
+		return
+	}
+	if scrapeConfig.RemainingElements == nil {
+		scrapeConfig.RemainingElements = prometheus.UntypedElement{}
+	}
+	scrapeConfig.RemainingElements[key] = map[string]interface{}(value)
+}
@@ -0,0 +1,104 @@
+package eventhandling
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
+	prometheus_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScrapeSettings are the default scrape settings of the helm chart
+var testScrapeSettings = scrapeSettings{ScrapeInterval: prometheus_model.Duration(5 * time.Second), MetricsPath: "/metrics"}
+
+func Test_getScrapeSettings(t *testing.T) {
+	resourceHandler := fakeResourceHandler{
+		resources: map[string]string{
+			"/v1/project/sockshop/resource/prometheus%2Fscrape.yaml": `
+scrape_interval: 30s
+metrics_path: /actuator/prometheus
+params:
+  format: [prometheus]
+`,
+			"/v1/project/sockshop/stage/production/service/carts/resource/prometheus%2Fscrape.yaml": `
+scrape_interval: 1m
+port: 8080
+scheme: https
+tls_config:
+  insecure_skip_verify: true
+`,
+		},
+	}
+
+	settings, err := getScrapeSettings(resourceHandler, "sockshop", "production", "carts", testScrapeSettings)
+	require.NoError(t, err)
+	assert.Equal(t, prometheus_model.Duration(time.Minute), settings.ScrapeInterval)
+	assert.Equal(t, defaultScrapeTimeout, settings.scrapeTimeout())
+	assert.Equal(t, "/actuator/prometheus", settings.MetricsPath)
+	assert.Equal(t, 8080, settings.Port)
+	assert.Equal(t, "https", settings.Scheme)
+	assert.Equal(t, url.Values{"format": []string{"prometheus"}}, settings.Params)
+	assert.Equal(t, true, settings.TLSConfig["insecure_skip_verify"])
+
+	// the defaults are used if no settings exist
+	settings, err = getScrapeSettings(resourceHandler, "other", "dev", "carts", testScrapeSettings)
+	require.NoError(t, err)
+	assert.Equal(t, testScrapeSettings, settings)
+
+	// unknown settings are rejected
+	resourceHandler.resources["/v1/project/other/resource/prometheus%2Fscrape.yaml"] = "interval: 1m"
+	_, err = getScrapeSettings(resourceHandler, "other", "dev", "carts", testScrapeSettings)
+	assert.Error(t, err)
+}
+
+func Test_scrapeSettingsScrapeTimeout(t *testing.T) {
+	// the default timeout is limited to the scrape interval
+	settings := scrapeSettings{ScrapeInterval: prometheus_model.Duration(time.Second)}
+	assert.Equal(t, prometheus_model.Duration(time.Second), settings.scrapeTimeout())
+
+	settings.ScrapeTimeout = prometheus_model.Duration(500 * time.Millisecond)
+	assert.Equal(t, prometheus_model.Duration(500*time.Millisecond), settings.scrapeTimeout())
+}
+
+func Test_createScrapeJobConfigWithScrapeSettings(t *testing.T) {
+	settings := testScrapeSettings.merge(scrapeSettings{
+		ScrapeTimeout: prometheus_model.Duration(4 * time.Second),
+		Port:          8080,
+		Scheme:        "https",
+		BasicAuth:     prometheus.UntypedElement{"username": "prometheus", "password_file": "/etc/secrets/carts"},
+		RelabelConfigs: []*prometheus.UntypedElement{
+			{"target_label": "team", "replacement": "checkout"},
+		},
+	})
+	config := &prometheus.Config{}
+
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, true, settings, scrapeTargetDiscoveryStatic))
+	scrapeConfig := config.ScrapeConfigs[0]
+	assert.Equal(t, []string{"carts-primary.sockshop-dev:8080"}, scrapeConfig.StaticConfigs[0].Targets)
+	assert.Equal(t, prometheus_model.Duration(4*time.Second), scrapeConfig.ScrapeTimeout)
+	assert.Equal(t, "https", scrapeConfig.Scheme)
+	assert.Equal(t, map[string]interface{}{"username": "prometheus", "password_file": "/etc/secrets/carts"}, scrapeConfig.RemainingElements["basic_auth"])
+	assert.Equal(t, settings.RelabelConfigs, scrapeConfig.RelabelConfigs)
+
+	// the container port is selected when discovering the endpoints
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, true, settings, scrapeTargetDiscoveryEndpoints))
+	relabelConfigs := scrapeConfig.RelabelConfigs
+	require.Len(t, relabelConfigs, 8)
+	assert.Equal(t, prometheus.UntypedElement{
+		"source_labels": []string{"__meta_kubernetes_pod_container_port_number"},
+		"regex":         "8080",
+		"action":        "keep",
+	}, *relabelConfigs[3])
+	assert.Equal(t, prometheus.UntypedElement{"target_label": "team", "replacement": "checkout"}, *relabelConfigs[4])
+	assert.Equal(t, managedRelabelConfigs("sockshop", "dev"), relabelConfigs[5:])
+
+	// settings are removed again if they are no longer configured
+	require.NoError(t, createScrapeJobConfig(config, "sockshop", "dev", "carts", false, true, testScrapeSettings, scrapeTargetDiscoveryStatic))
+	assert.NotContains(t, scrapeConfig.RemainingElements, "basic_auth")
+	assert.Empty(t, scrapeConfig.Scheme)
+	assert.Empty(t, scrapeConfig.RelabelConfigs)
+	assert.Equal(t, []string{"carts-primary.sockshop-dev:80"}, scrapeConfig.StaticConfigs[0].Targets)
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/kelseyhightower/envconfig"
 	"github.com/keptn-contrib/prometheus-service/eventhandling"
 	"github.com/keptn-contrib/prometheus-service/utils"
+	"github.com/keptn-contrib/prometheus-service/utils/prometheus"
 	"github.com/keptn/go-utils/pkg/sdk"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
@@ -22,6 +24,8 @@ import (
 
 var (
 	env utils.EnvConfig
+	// alertWebhookAuth verifies the credentials of the requests to the alert endpoint
+	alertWebhookAuth eventhandling.AlertWebhookAuth
 )
 
 const serviceName = "prometheus-service"
@@ -42,11 +46,26 @@ func main() {
 
 	log.Printf("Starting %s", serviceName)
 
+	if err := envconfig.Process("", &env); err != nil {
+		log.Fatalf("Failed to process env var: %v", err)
+	}
+	var err error
+	alertWebhookAuth, err = eventhandling.NewAlertWebhookAuth(env)
+	if err != nil {
+		log.Fatalf("Invalid authentication of the alert manager endpoint: %v", err)
+	}
+
 	// Creating an HTTP listener on port 8080 to receive alerts from Prometheus directly
 	http.HandleFunc("/", HTTPGetHandler)
 	go func() {
-		log.Println("Starting alert manager endpoint")
-		err := http.ListenAndServe(":8080", nil)
+		var err error
+		if env.AlertWebhookTLSCertFile != "" && env.AlertWebhookTLSKeyFile != "" {
+			log.Println("Starting alert manager endpoint with TLS")
+			err = http.ListenAndServeTLS(":8080", env.AlertWebhookTLSCertFile, env.AlertWebhookTLSKeyFile, nil)
+		} else {
+			log.Println("Starting alert manager endpoint")
+			err = http.ListenAndServe(":8080", nil)
+		}
 		if err != nil {
 			log.Fatalf("Error with HTTP server: %e", err)
 		}
@@ -72,6 +91,12 @@ func main() {
 			getSliTriggeredEvent,
 			eventhandling.NewGetSliEventHandler(*kubeClient),
 			prometheusSLIProviderFilter),
+		sdk.WithTaskHandler(
+			eventhandling.ServiceDeleteFinishedEvent,
+			eventhandling.NewDeleteMonitoringEventHandler()),
+		sdk.WithTaskHandler(
+			eventhandling.ProjectDeleteFinishedEvent,
+			eventhandling.NewDeleteMonitoringEventHandler()),
 		sdk.WithLogger(logrus.New()),
 	).Start())
 }
@@ -102,6 +127,12 @@ func prometheusTypeFilter(keptnHandle sdk.IKeptn, event sdk.KeptnEvent) bool {
 func HTTPGetHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/":
+		if !alertWebhookAuth.Authenticate(r) {
+			log.Printf("Rejected unauthenticated request to the alert manager endpoint from %s", r.RemoteAddr)
+			unauthorizedHandler(w, r)
+			return
+		}
+
 		shkeptncontext := uuid.New().String()
 		logger := keptncommon.NewLogger(shkeptncontext, "", utils.ServiceName)
 
@@ -111,7 +142,7 @@ func HTTPGetHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		eventhandling.ProcessAndForwardAlertEvent(w, body, logger, shkeptncontext)
+		eventhandling.ProcessAndForwardAlertEvent(w, body, logger)
 	case "/health":
 		healthEndpointHandler(w, r)
 	case "/ready":
@@ -139,6 +170,30 @@ func healthEndpointHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// unauthorizedHandler will return 401 for requests without valid credentials
+func unauthorizedHandler(w http.ResponseWriter, r *http.Request) {
+	type StatusBody struct {
+		Status string `json:"status"`
+	}
+
+	status := StatusBody{Status: "UNAUTHORIZED"}
+
+	body, _ := json.Marshal(status)
+
+	if alertWebhookAuth.Type == prometheus.AlertManagerAuthBasic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+serviceName+`"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+
+	_, err := w.Write(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 // endpointNotFoundHandler will return 404 for requests
 func endpointNotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	type StatusBody struct {
@@ -20,8 +20,24 @@ type EnvConfig struct {
 	AlertManagerConfigMap         string `envconfig:"ALERT_MANAGER_CM" default:""`
 	AlertManagerTemplateConfigMap string `envconfig:"ALERT_MANAGER_TEMPLATE_CM" default:"alertmanager-templates"`
 	PrometheusConfigFileName      string `envconfig:"PROMETHEUS_CONFIG_FILENAME" default:"prometheus.yml"`
+	PrometheusRulesFileName       string `envconfig:"PROMETHEUS_RULES_FILENAME" default:""`
 	AlertManagerConfigFileName    string `envconfig:"ALERT_MANAGER_CONFIG_FILENAME" default:"alertmanager.yml"`
+	AlertManagerReceiver          string `envconfig:"ALERT_MANAGER_RECEIVER" default:"keptn_integration"`
+	AlertManagerMatchers          string `envconfig:"ALERT_MANAGER_MATCHERS" default:"severity=\"webhook\""`
+	AlertManagerGroupBy           string `envconfig:"ALERT_MANAGER_GROUP_BY" default:""`
+	AlertManagerGroupWait         string `envconfig:"ALERT_MANAGER_GROUP_WAIT" default:"10s"`
+	AlertManagerRepeatInterval    string `envconfig:"ALERT_MANAGER_REPEAT_INTERVAL" default:"1m"`
 	PodNamespace                  string `envconfig:"POD_NAMESPACE" default:""`
 	PrometheusEndpoint            string `envconfig:"PROMETHEUS_ENDPOINT" default:""`
+	AlertManagerEndpoint          string `envconfig:"ALERT_MANAGER_ENDPOINT" default:""`
 	K8sNamespace                  string `envconfig:"K8S_NAMESPACE" required:"true"`
+	PrometheusConfigMode          string `envconfig:"PROMETHEUS_CONFIG_MODE" default:"auto"`
+	PrometheusOperatorLabels      string `envconfig:"PROMETHEUS_OPERATOR_LABELS" default:""`
+	AlertManagerConfigMode        string `envconfig:"ALERT_MANAGER_CONFIG_MODE" default:"auto"`
+	AlertWebhookAuth              string `envconfig:"ALERT_WEBHOOK_AUTH" default:""`
+	AlertWebhookUsername          string `envconfig:"ALERT_WEBHOOK_USERNAME" default:"keptn"`
+	AlertWebhookToken             string `envconfig:"ALERT_WEBHOOK_TOKEN" default:""`
+	AlertWebhookTLSCertFile       string `envconfig:"ALERT_WEBHOOK_TLS_CERT_FILE" default:""`
+	AlertWebhookTLSKeyFile        string `envconfig:"ALERT_WEBHOOK_TLS_KEY_FILE" default:""`
+	AlertWebhookCAFile            string `envconfig:"ALERT_WEBHOOK_CA_FILE" default:""`
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -22,6 +23,15 @@ func GetKubeClient() (*kubernetes.Clientset, error) {
 	return api, nil
 }
 
+// GetDynamicClient returns a k8s dynamic client using the in-cluster config
+func GetDynamicClient() (dynamic.Interface, error) {
+	k8sConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(k8sConfig)
+}
+
 // ListK8sServicesByLabel returns a k8s service list matching the passed labelSelector in the specified namespace
 func ListK8sServicesByLabel(svcLabelSelector, namespace string) (*v1.ServiceList, error) {
 	api, err := GetKubeClient()
@@ -0,0 +1,312 @@
+package prometheus
+
+import (
+	"fmt"
+
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultAlertManagerReceiver is the default name of the receiver forwarding alerts to prometheus-service
+const DefaultAlertManagerReceiver = "keptn_integration"
+
+// AlertManagerTemplates is the path of the notification templates mounted from the ALERT_MANAGER_TEMPLATE_CM configmap
+const AlertManagerTemplates = "/etc/alertmanager/*.tmpl"
+
+// Authorization types of the webhook of the receiver
+const (
+	AlertManagerAuthBearer = "bearer"
+	AlertManagerAuthBasic  = "basic"
+)
+
+// Keys of the secret referenced by the AlertmanagerConfig containing the credentials of the webhook
+const (
+	WebhookSecretUsernameKey = "username"
+	WebhookSecretTokenKey    = "token"
+)
+
+// AlertManagerIntegration describes the receiver and the route forwarding alerts to prometheus-service
+type AlertManagerIntegration struct {
+	// Receiver is the name of the receiver and of the receiver of the route
+	Receiver string
+	// WebhookURL is the URL of the alert endpoint of prometheus-service
+	WebhookURL string
+	// GroupBy contains the labels the alerts of the route are grouped by, the grouping of the parent route is used if
+	// it is empty
+	GroupBy        []string
+	GroupWait      string
+	RepeatInterval string
+	// Matchers select the alerts of the route, e.g. severity="webhook"
+	Matchers []string
+	// AuthType is the type of the credentials sent to the webhook (bearer or basic), no credentials are sent if it is
+	// empty
+	AuthType string
+	Username string
+	// Token is the bearer token or the password of the basic auth
+	Token string
+	// CAFile is the path of the CA certificate of the webhook in the Alertmanager container
+	CAFile string
+}
+
+// NewAlertManagerIntegration returns the default integration forwarding alerts with the severity webhook to the
+// prometheus-service running in the given namespace
+func NewAlertManagerIntegration(namespace string) AlertManagerIntegration {
+	return AlertManagerIntegration{
+		Receiver:       DefaultAlertManagerReceiver,
+		WebhookURL:     fmt.Sprintf("http://prometheus-service.%s.svc.cluster.local:8080", namespace),
+		GroupWait:      "10s",
+		RepeatInterval: "1m",
+		Matchers:       []string{`severity="webhook"`},
+	}
+}
+
+// ParseAlertManagerMatchers parses matchers like severity="webhook",team=~"checkout|payment" and returns them in the
+// format of the matchers of an Alertmanager route
+func ParseAlertManagerMatchers(value string) ([]string, error) {
+	matchers, err := labels.ParseMatchers(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, matcher := range matchers {
+		result = append(result, matcher.String())
+	}
+	return result, nil
+}
+
+// receiver returns the receiver of the integration
+func (i AlertManagerIntegration) receiver() yaml.MapSlice {
+	webhookConfig := yaml.MapSlice{{Key: "url", Value: i.WebhookURL}}
+	if httpConfig := i.httpConfig(); len(httpConfig) > 0 {
+		webhookConfig = append(webhookConfig, yaml.MapItem{Key: "http_config", Value: httpConfig})
+	}
+
+	return yaml.MapSlice{
+		{Key: "name", Value: i.Receiver},
+		{Key: "webhook_configs", Value: []interface{}{webhookConfig}},
+	}
+}
+
+// httpConfig returns the http_config of the webhook containing the credentials and the CA certificate
+func (i AlertManagerIntegration) httpConfig() yaml.MapSlice {
+	var httpConfig yaml.MapSlice
+	switch i.AuthType {
+	case AlertManagerAuthBearer:
+		httpConfig = append(httpConfig, yaml.MapItem{Key: "authorization", Value: yaml.MapSlice{
+			{Key: "type", Value: "Bearer"},
+			{Key: "credentials", Value: i.Token},
+		}})
+	case AlertManagerAuthBasic:
+		httpConfig = append(httpConfig, yaml.MapItem{Key: "basic_auth", Value: yaml.MapSlice{
+			{Key: "username", Value: i.Username},
+			{Key: "password", Value: i.Token},
+		}})
+	}
+	if i.CAFile != "" {
+		httpConfig = append(httpConfig, yaml.MapItem{Key: "tls_config", Value: yaml.MapSlice{{Key: "ca_file", Value: i.CAFile}}})
+	}
+	return httpConfig
+}
+
+// WebhookSecretData returns the content of the secret referenced by the AlertmanagerConfig of the integration or nil
+// if no credentials are sent to the webhook
+func (i AlertManagerIntegration) WebhookSecretData() map[string]string {
+	switch i.AuthType {
+	case AlertManagerAuthBearer:
+		return map[string]string{WebhookSecretTokenKey: i.Token}
+	case AlertManagerAuthBasic:
+		return map[string]string{WebhookSecretUsernameKey: i.Username, WebhookSecretTokenKey: i.Token}
+	}
+	return nil
+}
+
+// route returns the route of the integration
+func (i AlertManagerIntegration) route() yaml.MapSlice {
+	route := yaml.MapSlice{{Key: "receiver", Value: i.Receiver}}
+	if len(i.Matchers) > 0 {
+		route = append(route, yaml.MapItem{Key: "matchers", Value: i.Matchers})
+	}
+	if len(i.GroupBy) > 0 {
+		route = append(route, yaml.MapItem{Key: "group_by", Value: i.GroupBy})
+	}
+	if i.GroupWait != "" {
+		route = append(route, yaml.MapItem{Key: "group_wait", Value: i.GroupWait})
+	}
+	if i.RepeatInterval != "" {
+		route = append(route, yaml.MapItem{Key: "repeat_interval", Value: i.RepeatInterval})
+	}
+	return route
+}
+
+// AlertmanagerConfigSpec returns the spec of an AlertmanagerConfig of the Prometheus Operator containing the receiver
+// and the route of the integration. The credentials of the webhook are read from the given secret containing the
+// WebhookSecretData.
+func (i AlertManagerIntegration) AlertmanagerConfigSpec(secretName string) (map[string]interface{}, error) {
+	route := map[string]interface{}{"receiver": i.Receiver}
+	if len(i.Matchers) > 0 {
+		var matchers []interface{}
+		for _, value := range i.Matchers {
+			matcher, err := labels.ParseMatcher(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid matcher %s: %w", value, err)
+			}
+			matchers = append(matchers, map[string]interface{}{
+				"name":      matcher.Name,
+				"value":     matcher.Value,
+				"matchType": matcher.Type.String(),
+			})
+		}
+		route["matchers"] = matchers
+	}
+	if len(i.GroupBy) > 0 {
+		var groupBy []interface{}
+		for _, label := range i.GroupBy {
+			groupBy = append(groupBy, label)
+		}
+		route["groupBy"] = groupBy
+	}
+	if i.GroupWait != "" {
+		route["groupWait"] = i.GroupWait
+	}
+	if i.RepeatInterval != "" {
+		route["repeatInterval"] = i.RepeatInterval
+	}
+
+	webhookConfig := map[string]interface{}{"url": i.WebhookURL}
+	switch i.AuthType {
+	case AlertManagerAuthBearer:
+		webhookConfig["httpConfig"] = map[string]interface{}{
+			"authorization": map[string]interface{}{
+				"type":        "Bearer",
+				"credentials": secretKeySelector(secretName, WebhookSecretTokenKey),
+			},
+		}
+	case AlertManagerAuthBasic:
+		webhookConfig["httpConfig"] = map[string]interface{}{
+			"basicAuth": map[string]interface{}{
+				"username": secretKeySelector(secretName, WebhookSecretUsernameKey),
+				"password": secretKeySelector(secretName, WebhookSecretTokenKey),
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"receivers": []interface{}{
+			map[string]interface{}{
+				"name":           i.Receiver,
+				"webhookConfigs": []interface{}{webhookConfig},
+			},
+		},
+		"route": route,
+	}, nil
+}
+
+func secretKeySelector(name string, key string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "key": key}
+}
+
+// MergeAlertManagerConfig adds the receiver and the route of the integration to the given Alertmanager configuration or
+// replaces them if they already exist, and returns whether the configuration has been changed. A new route is inserted
+// before all other routes, so that alerts are not taken by a route matching them as well. The notification templates
+// are added if they are missing. All other settings, including secrets, are preserved as they are.
+func MergeAlertManagerConfig(content string, integration AlertManagerIntegration) (string, bool, error) {
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		return "", false, err
+	}
+	original, err := yaml.Marshal(config)
+	if err != nil {
+		return "", false, err
+	}
+
+	receivers, _ := getMapSliceValue(config, "receivers").([]interface{})
+	config = setMapSliceValue(config, "receivers", upsertNamedItem(receivers, "name", integration.Receiver, integration.receiver()))
+
+	route, _ := getMapSliceValue(config, "route").(yaml.MapSlice)
+	if route == nil {
+		// alerts that are not matched by a route are sent to the receiver of the top-level route
+		route = yaml.MapSlice{{Key: "receiver", Value: integration.Receiver}}
+	}
+	routes, _ := getMapSliceValue(route, "routes").([]interface{})
+	route = setMapSliceValue(route, "routes", upsertNamedItemFirst(routes, "receiver", integration.Receiver, integration.route()))
+	config = setMapSliceValue(config, "route", route)
+
+	templates, _ := getMapSliceValue(config, "templates").([]interface{})
+	if !containsItem(templates, AlertManagerTemplates) {
+		config = setMapSliceValue(config, "templates", append(templates, AlertManagerTemplates))
+	}
+
+	updated, err := yaml.Marshal(config)
+	if err != nil {
+		return "", false, err
+	}
+	if string(updated) == string(original) {
+		return content, false, nil
+	}
+
+	if err := ValidateAlertManagerConfigYAML(string(updated)); err != nil {
+		return "", false, fmt.Errorf("generated Alertmanager configuration is invalid: %w", err)
+	}
+	return string(updated), true, nil
+}
+
+// upsertNamedItem replaces the first item of the list whose key has the given value or appends the item
+func upsertNamedItem(items []interface{}, key string, value string, item yaml.MapSlice) []interface{} {
+	if i := indexOfNamedItem(items, key, value); i >= 0 {
+		items[i] = item
+		return items
+	}
+	return append(items, item)
+}
+
+// upsertNamedItemFirst replaces the first item of the list whose key has the given value or inserts the item at the
+// front of the list
+func upsertNamedItemFirst(items []interface{}, key string, value string, item yaml.MapSlice) []interface{} {
+	if i := indexOfNamedItem(items, key, value); i >= 0 {
+		items[i] = item
+		return items
+	}
+	return append([]interface{}{item}, items...)
+}
+
+// indexOfNamedItem returns the index of the first item of the list whose key has the given value or -1
+func indexOfNamedItem(items []interface{}, key string, value string) int {
+	for i, existing := range items {
+		if existing, ok := existing.(yaml.MapSlice); ok && getMapSliceValue(existing, key) == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsItem returns true if the list contains the given value
+func containsItem(items []interface{}, value interface{}) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getMapSliceValue returns the value of the key or nil if the key does not exist
+func getMapSliceValue(mapSlice yaml.MapSlice, key string) interface{} {
+	for _, item := range mapSlice {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// setMapSliceValue sets the value of the key, keeping the position of an existing key
+func setMapSliceValue(mapSlice yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range mapSlice {
+		if item.Key == key {
+			mapSlice[i].Value = value
+			return mapSlice
+		}
+	}
+	return append(mapSlice, yaml.MapItem{Key: key, Value: value})
+}
@@ -0,0 +1,278 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testAlertManagerYaml = `global:
+  resolve_timeout: 5m
+route:
+  receiver: default
+receivers:
+- name: default
+`
+
+func TestPrometheusHelper_UpdateAMConfigMapRetriesOnConflict(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-alertmanager", Namespace: "monitoring"},
+		Data:       map[string]string{"alertmanager.yml": testAlertManagerYaml},
+	})
+
+	conflicts := 0
+	clientSet.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts < 2 {
+			conflicts++
+			return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "prometheus-alertmanager", nil)
+		}
+		return false, nil, nil
+	})
+
+	helper := &PrometheusHelper{KubeAPI: clientSet, Namespace: "keptn"}
+	require.NoError(t, helper.UpdateAMConfigMap("prometheus-alertmanager", "alertmanager.yml", "monitoring"))
+	assert.Equal(t, 2, conflicts)
+
+	cm, err := clientSet.CoreV1().ConfigMaps("monitoring").Get(context.TODO(), "prometheus-alertmanager", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data["alertmanager.yml"], "keptn_integration")
+}
+
+func TestPrometheusHelper_DiffAMConfigMap(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-alertmanager", Namespace: "monitoring"},
+		Data:       map[string]string{"alertmanager.yml": testAlertManagerYaml},
+	})
+	helper := &PrometheusHelper{KubeAPI: clientSet, Namespace: "keptn"}
+
+	diff, err := helper.DiffAMConfigMap("prometheus-alertmanager", "alertmanager.yml", "monitoring")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "--- a/alertmanager.yml\n+++ b/alertmanager.yml\n")
+	assert.Contains(t, diff, "+- name: keptn_integration\n")
+
+	// the configmap is not modified
+	cm, err := clientSet.CoreV1().ConfigMaps("monitoring").Get(context.TODO(), "prometheus-alertmanager", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, testAlertManagerYaml, cm.Data["alertmanager.yml"])
+
+	// no diff once the receiver has been added
+	require.NoError(t, helper.UpdateAMConfigMap("prometheus-alertmanager", "alertmanager.yml", "monitoring"))
+	diff, err = helper.DiffAMConfigMap("prometheus-alertmanager", "alertmanager.yml", "monitoring")
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+const testAlertManagerYamlWithSecrets = `global:
+  resolve_timeout: 5m
+  slack_api_url: https://hooks.slack.com/services/T000/B000/XXXX
+route:
+  receiver: slack
+  group_by: [alertname]
+  routes:
+  - receiver: keptn_integration
+    match:
+      severity: webhook
+  - receiver: slack
+    matchers: ['team="checkout"']
+receivers:
+- name: slack
+  slack_configs:
+  - channel: '#alerts'
+    send_resolved: true
+- name: keptn_integration
+  webhook_configs:
+  - url: http://prometheus-service.old.svc.cluster.local:8080
+`
+
+func TestMergeAlertManagerConfig(t *testing.T) {
+	updated, changed, err := MergeAlertManagerConfig(testAlertManagerYamlWithSecrets, NewAlertManagerIntegration("keptn"))
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// secrets and unrelated settings are preserved
+	assert.Contains(t, updated, "slack_api_url: https://hooks.slack.com/services/T000/B000/XXXX\n")
+	assert.Contains(t, updated, "- channel: '#alerts'\n")
+	assert.Contains(t, updated, "  - receiver: slack\n    matchers:\n    - team=\"checkout\"\n")
+	assert.NotContains(t, updated, "<secret>")
+
+	// the existing route and receiver are replaced, using matchers instead of match
+	assert.Contains(t, updated, "  - receiver: keptn_integration\n    matchers:\n    - severity=\"webhook\"\n    group_wait: 10s\n    repeat_interval: 1m\n")
+	assert.NotContains(t, updated, "match:")
+	assert.Contains(t, updated, "- url: http://prometheus-service.keptn.svc.cluster.local:8080\n")
+	assert.NotContains(t, updated, "prometheus-service.old")
+
+	// merging again does not change the configuration
+	again, changed, err := MergeAlertManagerConfig(updated, NewAlertManagerIntegration("keptn"))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, updated, again)
+}
+
+func TestMergeAlertManagerConfigCustomIntegration(t *testing.T) {
+	integration := AlertManagerIntegration{
+		Receiver:       "keptn",
+		WebhookURL:     "http://prometheus-service.keptn.svc.cluster.local:8080",
+		GroupBy:        []string{"alertname", "service"},
+		RepeatInterval: "5m",
+	}
+
+	updated, changed, err := MergeAlertManagerConfig("", integration)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `receivers:
+- name: keptn
+  webhook_configs:
+  - url: http://prometheus-service.keptn.svc.cluster.local:8080
+route:
+  receiver: keptn
+  routes:
+  - receiver: keptn
+    group_by:
+    - alertname
+    - service
+    repeat_interval: 5m
+templates:
+- /etc/alertmanager/*.tmpl
+`, updated)
+}
+
+func TestMergeAlertManagerConfigCatchAllRoute(t *testing.T) {
+	content := `route:
+  receiver: default
+  routes:
+  - receiver: default
+    continue: false
+templates:
+- /etc/alertmanager/custom/*.tmpl
+receivers:
+- name: default
+`
+
+	updated, changed, err := MergeAlertManagerConfig(content, NewAlertManagerIntegration("keptn"))
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// the route is inserted before the catch-all route, which would take the alerts otherwise
+	assert.Contains(t, updated, `  routes:
+  - receiver: keptn_integration
+    matchers:
+    - severity="webhook"
+    group_wait: 10s
+    repeat_interval: 1m
+  - receiver: default
+    continue: false
+`)
+	// the notification templates are added to the existing templates
+	assert.Contains(t, updated, `templates:
+- /etc/alertmanager/custom/*.tmpl
+- /etc/alertmanager/*.tmpl
+`)
+
+	// merging again does not change the configuration
+	again, changed, err := MergeAlertManagerConfig(updated, NewAlertManagerIntegration("keptn"))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, updated, again)
+}
+
+func TestMergeAlertManagerConfigWebhookCredentials(t *testing.T) {
+	integration := NewAlertManagerIntegration("keptn")
+	integration.WebhookURL = "https://prometheus-service.keptn.svc.cluster.local:8080"
+	integration.AuthType = AlertManagerAuthBearer
+	integration.Token = "s3cr3t"
+	integration.CAFile = "/etc/alertmanager/secrets/keptn/ca.crt"
+
+	updated, _, err := MergeAlertManagerConfig(testAlertManagerYaml, integration)
+	require.NoError(t, err)
+	assert.Contains(t, updated, `  - url: https://prometheus-service.keptn.svc.cluster.local:8080
+    http_config:
+      authorization:
+        type: Bearer
+        credentials: s3cr3t
+      tls_config:
+        ca_file: /etc/alertmanager/secrets/keptn/ca.crt
+`)
+
+	integration.AuthType = AlertManagerAuthBasic
+	integration.Username = "keptn"
+	integration.CAFile = ""
+	updated, _, err = MergeAlertManagerConfig(updated, integration)
+	require.NoError(t, err)
+	assert.Contains(t, updated, `    http_config:
+      basic_auth:
+        username: keptn
+        password: s3cr3t
+`)
+	assert.NotContains(t, updated, "authorization:")
+}
+
+func TestAlertManagerIntegration_AlertmanagerConfigSpecCredentials(t *testing.T) {
+	integration := NewAlertManagerIntegration("keptn")
+	integration.AuthType = AlertManagerAuthBearer
+	integration.Token = "s3cr3t"
+
+	spec, err := integration.AlertmanagerConfigSpec("prometheus-service-webhook")
+	require.NoError(t, err)
+	webhookConfig := spec["receivers"].([]interface{})[0].(map[string]interface{})["webhookConfigs"].([]interface{})[0]
+	assert.Equal(t, map[string]interface{}{
+		"authorization": map[string]interface{}{
+			"type":        "Bearer",
+			"credentials": map[string]interface{}{"name": "prometheus-service-webhook", "key": "token"},
+		},
+	}, webhookConfig.(map[string]interface{})["httpConfig"])
+	assert.Equal(t, map[string]string{"token": "s3cr3t"}, integration.WebhookSecretData())
+
+	integration.AuthType = ""
+	assert.Nil(t, integration.WebhookSecretData())
+}
+
+func TestParseAlertManagerMatchers(t *testing.T) {
+	matchers, err := ParseAlertManagerMatchers(`severity="webhook",team=~"checkout|payment"`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`severity="webhook"`, `team=~"checkout|payment"`}, matchers)
+
+	_, err = ParseAlertManagerMatchers(`severity=~"("`)
+	assert.Error(t, err)
+}
+
+func TestAlertManagerIntegration_AlertmanagerConfigSpec(t *testing.T) {
+	integration := NewAlertManagerIntegration("keptn")
+	integration.Matchers = []string{`severity="webhook"`, `team=~"checkout|payment"`}
+	integration.GroupBy = []string{"alertname"}
+
+	spec, err := integration.AlertmanagerConfigSpec("prometheus-service-webhook")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"receivers": []interface{}{
+			map[string]interface{}{
+				"name": "keptn_integration",
+				"webhookConfigs": []interface{}{
+					map[string]interface{}{"url": "http://prometheus-service.keptn.svc.cluster.local:8080"},
+				},
+			},
+		},
+		"route": map[string]interface{}{
+			"receiver": "keptn_integration",
+			"matchers": []interface{}{
+				map[string]interface{}{"name": "severity", "value": "webhook", "matchType": "="},
+				map[string]interface{}{"name": "team", "value": "checkout|payment", "matchType": "=~"},
+			},
+			"groupBy":        []interface{}{"alertname"},
+			"groupWait":      "10s",
+			"repeatInterval": "1m",
+		},
+	}, spec)
+
+	integration.Matchers = []string{"severity"}
+	_, err = integration.AlertmanagerConfigSpec("prometheus-service-webhook")
+	assert.Error(t, err)
+}
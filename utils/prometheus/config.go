@@ -55,6 +55,7 @@ type ScrapeConfig struct {
 	LabelValueLengthLimit uint             `mapstructure:"label_value_length_limit,omitempty" yaml:"label_value_length_limit,omitempty"`
 
 	StaticConfigs        []StaticConfigLike `mapstructure:"static_configs,omitempty" yaml:"static_configs,omitempty"`
+	KubernetesSDConfigs  []*UntypedElement  `mapstructure:"kubernetes_sd_configs,omitempty" yaml:"kubernetes_sd_configs,omitempty"`
 	RelabelConfigs       []*UntypedElement  `mapstructure:"relabel_configs,omitempty" yaml:"relabel_configs,omitempty"`
 	MetricRelabelConfigs []*UntypedElement  `mapstructure:"metric_relabel_configs,omitempty" yaml:"metric_relabel_configs,omitempty"`
 
@@ -0,0 +1,188 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/api"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// DatasourceType identifies the flavour of the Prometheus compatible API that is queried
+type DatasourceType string
+
+// DatasourceTypePrometheus identifies a plain Prometheus API
+const DatasourceTypePrometheus DatasourceType = "prometheus"
+
+// DatasourceTypeThanos identifies a Thanos Query API
+const DatasourceTypeThanos DatasourceType = "thanos"
+
+// DatasourceTypeVictoriaMetrics identifies a VictoriaMetrics (vmselect) API
+const DatasourceTypeVictoriaMetrics DatasourceType = "victoriametrics"
+
+// DatasourceTypeMimir identifies a Grafana Mimir API
+const DatasourceTypeMimir DatasourceType = "mimir"
+
+// QueryOptions holds the datasource type specific parameters that are added to every query
+type QueryOptions struct {
+	// Thanos, see https://thanos.io/tip/components/query.md/#query-api-overview
+	PartialResponse     *bool    `json:"partial_response,omitempty" yaml:"partial_response,omitempty"`
+	Dedup               *bool    `json:"dedup,omitempty" yaml:"dedup,omitempty"`
+	MaxSourceResolution string   `json:"max_source_resolution,omitempty" yaml:"max_source_resolution,omitempty"`
+	ReplicaLabels       []string `json:"replica_labels,omitempty" yaml:"replica_labels,omitempty"`
+
+	// VictoriaMetrics, see https://docs.victoriametrics.com/#prometheus-querying-api-enhancements
+	ExtraLabels  []string `json:"extra_label,omitempty" yaml:"extra_label,omitempty"`
+	ExtraFilters []string `json:"extra_filters,omitempty" yaml:"extra_filters,omitempty"`
+	NoCache      *bool    `json:"nocache,omitempty" yaml:"nocache,omitempty"`
+
+	// Mimir, see https://grafana.com/docs/mimir/latest/operators-guide/secure/authentication-and-authorization/
+	TenantID string `json:"tenant_id,omitempty" yaml:"tenant_id,omitempty"`
+}
+
+// Merge returns a copy of the options in which all fields that are set in override have been replaced
+func (o QueryOptions) Merge(override QueryOptions) QueryOptions {
+	if override.PartialResponse != nil {
+		o.PartialResponse = override.PartialResponse
+	}
+	if override.Dedup != nil {
+		o.Dedup = override.Dedup
+	}
+	if override.MaxSourceResolution != "" {
+		o.MaxSourceResolution = override.MaxSourceResolution
+	}
+	if len(override.ReplicaLabels) > 0 {
+		o.ReplicaLabels = override.ReplicaLabels
+	}
+	if len(override.ExtraLabels) > 0 {
+		o.ExtraLabels = override.ExtraLabels
+	}
+	if len(override.ExtraFilters) > 0 {
+		o.ExtraFilters = override.ExtraFilters
+	}
+	if override.NoCache != nil {
+		o.NoCache = override.NoCache
+	}
+	if override.TenantID != "" {
+		o.TenantID = override.TenantID
+	}
+	return o
+}
+
+// requestModifiers hold the functions that add the type specific options to a request for each datasource type
+var requestModifiers = map[DatasourceType]func(options QueryOptions, req *http.Request){
+	DatasourceTypePrometheus:      func(QueryOptions, *http.Request) {},
+	DatasourceTypeThanos:          applyThanosOptions,
+	DatasourceTypeVictoriaMetrics: applyVictoriaMetricsOptions,
+	DatasourceTypeMimir:           applyMimirOptions,
+}
+
+func applyThanosOptions(options QueryOptions, req *http.Request) {
+	query := req.URL.Query()
+	if options.PartialResponse != nil {
+		query.Set("partial_response", strconv.FormatBool(*options.PartialResponse))
+	}
+	if options.Dedup != nil {
+		query.Set("dedup", strconv.FormatBool(*options.Dedup))
+	}
+	if options.MaxSourceResolution != "" {
+		query.Set("max_source_resolution", options.MaxSourceResolution)
+	}
+	if len(options.ReplicaLabels) > 0 {
+		query["replicaLabels[]"] = options.ReplicaLabels
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+func applyVictoriaMetricsOptions(options QueryOptions, req *http.Request) {
+	query := req.URL.Query()
+	if len(options.ExtraLabels) > 0 {
+		query["extra_label"] = options.ExtraLabels
+	}
+	if len(options.ExtraFilters) > 0 {
+		query["extra_filters[]"] = options.ExtraFilters
+	}
+	if options.NoCache != nil && *options.NoCache {
+		query.Set("nocache", "1")
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+func applyMimirOptions(options QueryOptions, req *http.Request) {
+	if options.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", options.TenantID)
+	}
+}
+
+// Datasource describes a Prometheus compatible API together with the options that are added to its queries
+type Datasource struct {
+	URL              string
+	Type             DatasourceType
+	Options          QueryOptions
+	IndicatorOptions map[string]QueryOptions
+}
+
+// IsSupportedDatasourceType returns true if the given datasource type is known
+func IsSupportedDatasourceType(datasourceType DatasourceType) bool {
+	_, ok := requestModifiers[datasourceType]
+	return ok
+}
+
+// NewAPI returns an API for the datasource that adds the type specific options to every query. The options of an
+// indicator are merged over the default options if the indicator has been attached to the context of the query.
+func (d Datasource) NewAPI() (API, error) {
+	modifier, ok := requestModifiers[d.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported datasource type %s", d.Type)
+	}
+
+	apiClient, err := api.NewClient(api.Config{
+		Address: d.URL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return apiv1.NewAPI(&datasourceClient{
+		Client:           apiClient,
+		modifier:         modifier,
+		options:          d.Options,
+		indicatorOptions: d.IndicatorOptions,
+	}), nil
+}
+
+// datasourceClient wraps an api.Client and adds the datasource specific options to every request
+type datasourceClient struct {
+	api.Client
+	modifier         func(options QueryOptions, req *http.Request)
+	options          QueryOptions
+	indicatorOptions map[string]QueryOptions
+}
+
+// Do adds the options for the indicator stored in the context and executes the request
+func (c *datasourceClient) Do(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+	options := c.options
+	if indicator, ok := IndicatorFromContext(ctx); ok {
+		if indicatorOptions, ok := c.indicatorOptions[indicator]; ok {
+			options = options.Merge(indicatorOptions)
+		}
+	}
+
+	c.modifier(options, req)
+	return c.Client.Do(ctx, req)
+}
+
+type indicatorContextKey struct{}
+
+// ContextWithIndicator returns a copy of ctx that carries the name of the indicator that is queried
+func ContextWithIndicator(ctx context.Context, indicator string) context.Context {
+	return context.WithValue(ctx, indicatorContextKey{}, indicator)
+}
+
+// IndicatorFromContext returns the name of the indicator that is queried if it has been stored in ctx
+func IndicatorFromContext(ctx context.Context) (string, bool) {
+	indicator, ok := ctx.Value(indicatorContextKey{}).(string)
+	return indicator, ok
+}
@@ -0,0 +1,153 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vectorResponse = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1571649085,"1"]}]}}`
+
+// newRecordingServer returns a server that stores the parameters and headers of the last request
+func newRecordingServer(t *testing.T, parameters *url.Values, headers *http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		*parameters = r.Form
+		*headers = r.Header
+		_, _ = w.Write([]byte(vectorResponse))
+	}))
+}
+
+func TestDatasource_NewAPIThanos(t *testing.T) {
+	var receivedParameters url.Values
+	var receivedHeaders http.Header
+	server := newRecordingServer(t, &receivedParameters, &receivedHeaders)
+	defer server.Close()
+
+	partialResponse := false
+	dedup := true
+	api, err := Datasource{
+		URL:  server.URL,
+		Type: DatasourceTypeThanos,
+		Options: QueryOptions{
+			PartialResponse: &partialResponse,
+			Dedup:           &dedup,
+			ReplicaLabels:   []string{"replica", "rule_replica"},
+			TenantID:        "ignored",
+		},
+		IndicatorOptions: map[string]QueryOptions{
+			ErrorRate: {MaxSourceResolution: "1h"},
+		},
+	}.NewAPI()
+	require.NoError(t, err)
+
+	_, _, err = api.Query(context.TODO(), "up", time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, "up", receivedParameters.Get("query"))
+	assert.Equal(t, "false", receivedParameters.Get("partial_response"))
+	assert.Equal(t, "true", receivedParameters.Get("dedup"))
+	assert.Equal(t, []string{"replica", "rule_replica"}, receivedParameters["replicaLabels[]"])
+	assert.Empty(t, receivedParameters.Get("max_source_resolution"))
+	assert.Empty(t, receivedHeaders.Get("X-Scope-OrgID"))
+
+	_, _, err = api.Query(ContextWithIndicator(context.TODO(), ErrorRate), "up", time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, "false", receivedParameters.Get("partial_response"))
+	assert.Equal(t, "1h", receivedParameters.Get("max_source_resolution"))
+}
+
+func TestDatasource_NewAPIVictoriaMetrics(t *testing.T) {
+	var receivedParameters url.Values
+	var receivedHeaders http.Header
+	server := newRecordingServer(t, &receivedParameters, &receivedHeaders)
+	defer server.Close()
+
+	noCache := true
+	api, err := Datasource{
+		URL:  server.URL,
+		Type: DatasourceTypeVictoriaMetrics,
+		Options: QueryOptions{
+			ExtraLabels:  []string{"env=prod"},
+			ExtraFilters: []string{`{team="a",app!="b"}`},
+			NoCache:      &noCache,
+		},
+	}.NewAPI()
+	require.NoError(t, err)
+
+	_, _, err = api.Query(ContextWithIndicator(context.TODO(), Throughput), "up", time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"env=prod"}, receivedParameters["extra_label"])
+	assert.Equal(t, []string{`{team="a",app!="b"}`}, receivedParameters["extra_filters[]"])
+	assert.Equal(t, "1", receivedParameters.Get("nocache"))
+}
+
+func TestDatasource_NewAPIMimir(t *testing.T) {
+	var receivedParameters url.Values
+	var receivedHeaders http.Header
+	server := newRecordingServer(t, &receivedParameters, &receivedHeaders)
+	defer server.Close()
+
+	api, err := Datasource{
+		URL:     server.URL,
+		Type:    DatasourceTypeMimir,
+		Options: QueryOptions{TenantID: "team-a"},
+		IndicatorOptions: map[string]QueryOptions{
+			ErrorRate: {TenantID: "team-b"},
+		},
+	}.NewAPI()
+	require.NoError(t, err)
+
+	_, _, err = api.Query(context.TODO(), "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", receivedHeaders.Get("X-Scope-OrgID"))
+
+	_, _, err = api.Query(ContextWithIndicator(context.TODO(), ErrorRate), "up", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "team-b", receivedHeaders.Get("X-Scope-OrgID"))
+}
+
+func TestDatasource_NewAPIUnsupportedType(t *testing.T) {
+	_, err := Datasource{
+		URL:  "http://localhost:9090",
+		Type: "influxdb",
+	}.NewAPI()
+	require.Error(t, err)
+}
+
+func TestQueryOptions_Merge(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	base := QueryOptions{
+		PartialResponse:     &enabled,
+		MaxSourceResolution: "5m",
+		ReplicaLabels:       []string{"replica"},
+	}
+
+	merged := base.Merge(QueryOptions{
+		PartialResponse: &disabled,
+		Dedup:           &enabled,
+		TenantID:        "team-a",
+	})
+
+	assert.Equal(t, QueryOptions{
+		PartialResponse:     &disabled,
+		Dedup:               &enabled,
+		MaxSourceResolution: "5m",
+		ReplicaLabels:       []string{"replica"},
+		TenantID:            "team-a",
+	}, merged)
+
+	// base must not be modified
+	assert.Equal(t, &enabled, base.PartialResponse)
+	assert.Nil(t, base.Dedup)
+}
@@ -0,0 +1,34 @@
+package prometheus
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// UnifiedDiff returns the unified diff between the old and the new content of the given file, or an empty string if
+// the content has not changed
+func UnifiedDiff(fileName string, oldContent string, newContent string) (string, error) {
+	if oldContent == newContent {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldContent),
+		B:        splitLines(newContent),
+		FromFile: "a/" + fileName,
+		ToFile:   "b/" + fileName,
+		Context:  3,
+	})
+}
+
+// splitLines splits the content into lines keeping their line breaks, a missing line break at the end of the content
+// is added so that it does not show up as a change of the last line
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(content, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
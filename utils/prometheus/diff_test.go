@@ -0,0 +1,18 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := UnifiedDiff("prometheus.yml", "scrape_configs:\n- job_name: a\n", "scrape_configs:\n- job_name: a\n- job_name: b\n")
+	require.NoError(t, err)
+	assert.Equal(t, "--- a/prometheus.yml\n+++ b/prometheus.yml\n@@ -1,2 +1,3 @@\n scrape_configs:\n - job_name: a\n+- job_name: b\n", diff)
+
+	diff, err = UnifiedDiff("prometheus.yml", "global: {}\n", "global: {}\n")
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
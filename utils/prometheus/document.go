@@ -0,0 +1,269 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yamlv2 "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// lineEdit replaces the lines [start, end) of a document with text, an insertion has start == end
+type lineEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// PatchYamlConfiguration returns the original prometheus.yml with the scrape configs that have been added, modified or
+// removed in the given configuration and the added rule files patched into it. All other parts of the document,
+// including comments, key order, anchors and the formatting of unmodified scrape configs, are preserved byte-for-byte.
+// Changes to other parts of the configuration are not written.
+func PatchYamlConfiguration(original string, config *Config) (string, error) {
+	if strings.TrimSpace(original) == "" {
+		content, err := yamlv2.Marshal(config)
+		return string(content), err
+	}
+
+	originalConfig, err := LoadYamlConfiguration(original)
+	if err != nil {
+		return "", err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(original), &document); err != nil {
+		return "", err
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("prometheus configuration is not a YAML mapping")
+	}
+	root := document.Content[0]
+
+	if !strings.HasSuffix(original, "\n") {
+		original += "\n"
+	}
+	lines := strings.SplitAfter(original, "\n")
+	lines = lines[:len(lines)-1]
+
+	var edits []lineEdit
+
+	scrapeConfigEdits, err := getScrapeConfigEdits(lines, root, originalConfig.ScrapeConfigs, config.ScrapeConfigs)
+	if err != nil {
+		return "", err
+	}
+	edits = append(edits, scrapeConfigEdits...)
+
+	ruleFileEdits, err := getRuleFileEdits(lines, root, originalConfig.RuleFiles, config.RuleFiles)
+	if err != nil {
+		return "", err
+	}
+	edits = append(edits, ruleFileEdits...)
+
+	return applyLineEdits(lines, edits), nil
+}
+
+// getScrapeConfigEdits returns the edits replacing the modified, removing the deleted and appending the new scrape
+// configs, scrape configs are identified by their job name
+func getScrapeConfigEdits(lines []string, root *yaml.Node, original []*ScrapeConfig, modified []*ScrapeConfig) ([]lineEdit, error) {
+	modifiedByName := map[string]*ScrapeConfig{}
+	for _, scrapeConfig := range modified {
+		modifiedByName[scrapeConfig.JobName] = scrapeConfig
+	}
+
+	originalByName := map[string]*ScrapeConfig{}
+	for _, scrapeConfig := range original {
+		originalByName[scrapeConfig.JobName] = scrapeConfig
+	}
+
+	key, sequence := getMappingEntry(root, "scrape_configs")
+	if sequence == nil || sequence.Kind != yaml.SequenceNode || sequence.Style&yaml.FlowStyle != 0 || len(sequence.Content) == 0 {
+		// there is no block sequence that could be patched, therefore the whole section is written if it has changed
+		if scrapeConfigsEqual(original, modified) {
+			return nil, nil
+		}
+		return replaceMappingEntry(lines, root, key, sequence, "scrape_configs", modified)
+	}
+
+	var edits []lineEdit
+	for _, item := range sequence.Content {
+		jobName := getMappingValue(item, "job_name")
+		start, end := getItemStart(lines, item), lastLine(item)
+		indent := getItemIndent(lines[start])
+
+		modifiedScrapeConfig, ok := modifiedByName[jobName]
+		if !ok {
+			edits = append(edits, lineEdit{start: start, end: end})
+			continue
+		}
+		if scrapeConfigsEqual([]*ScrapeConfig{originalByName[jobName]}, []*ScrapeConfig{modifiedScrapeConfig}) {
+			continue
+		}
+
+		text, err := renderSequence(indent, []*ScrapeConfig{modifiedScrapeConfig})
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, lineEdit{start: start, end: end, text: text})
+	}
+
+	var added []*ScrapeConfig
+	for _, scrapeConfig := range modified {
+		if _, ok := originalByName[scrapeConfig.JobName]; !ok {
+			added = append(added, scrapeConfig)
+		}
+	}
+	if len(added) > 0 {
+		lastItem := sequence.Content[len(sequence.Content)-1]
+		text, err := renderSequence(getItemIndent(lines[getItemStart(lines, lastItem)]), added)
+		if err != nil {
+			return nil, err
+		}
+		end := lastLine(lastItem)
+		edits = append(edits, lineEdit{start: end, end: end, text: text})
+	}
+
+	return edits, nil
+}
+
+// getRuleFileEdits returns the edit appending the rule files that have been added to the configuration
+func getRuleFileEdits(lines []string, root *yaml.Node, original []string, modified []string) ([]lineEdit, error) {
+	existing := map[string]bool{}
+	for _, ruleFile := range original {
+		existing[ruleFile] = true
+	}
+
+	var added []string
+	for _, ruleFile := range modified {
+		if !existing[ruleFile] {
+			added = append(added, ruleFile)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	key, sequence := getMappingEntry(root, "rule_files")
+	if sequence == nil || sequence.Kind != yaml.SequenceNode || sequence.Style&yaml.FlowStyle != 0 || len(sequence.Content) == 0 {
+		return replaceMappingEntry(lines, root, key, sequence, "rule_files", append(append([]string{}, original...), added...))
+	}
+
+	lastItem := sequence.Content[len(sequence.Content)-1]
+	text, err := renderSequence(getItemIndent(lines[getItemStart(lines, lastItem)]), added)
+	if err != nil {
+		return nil, err
+	}
+	end := lastLine(lastItem)
+	return []lineEdit{{start: end, end: end, text: text}}, nil
+}
+
+// replaceMappingEntry returns the edit replacing the entry of the root mapping with the given key and value, or
+// appending it to the document if it does not exist
+func replaceMappingEntry(lines []string, root *yaml.Node, key *yaml.Node, value *yaml.Node, name string, items interface{}) ([]lineEdit, error) {
+	indent := ""
+	start, end := len(lines), len(lines)
+	if key != nil {
+		indent = strings.Repeat(" ", key.Column-1)
+		start, end = key.Line-1, lastLine(value)
+		if end < key.Line {
+			end = key.Line
+		}
+	}
+
+	text, err := renderSequence(indent, items)
+	if err != nil {
+		return nil, err
+	}
+	return []lineEdit{{start: start, end: end, text: indent + name + ":\n" + text}}, nil
+}
+
+// renderSequence renders the items as YAML block sequence indented by the given prefix
+func renderSequence(indent string, items interface{}) (string, error) {
+	content, err := yamlv2.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if line != "" {
+			result.WriteString(indent + line)
+		}
+	}
+	return result.String(), nil
+}
+
+// applyLineEdits applies the edits, which must not overlap, to the lines and returns the resulting document
+func applyLineEdits(lines []string, edits []lineEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	for _, edit := range edits {
+		result := append([]string{}, lines[:edit.start]...)
+		if edit.text != "" {
+			result = append(result, edit.text)
+		}
+		lines = append(result, lines[edit.end:]...)
+	}
+	return strings.Join(lines, "")
+}
+
+// getMappingEntry returns the key and value node of the given key of a mapping
+func getMappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// getMappingValue returns the scalar value of the given key of a mapping
+func getMappingValue(mapping *yaml.Node, key string) string {
+	_, value := getMappingEntry(mapping, key)
+	if value == nil {
+		return ""
+	}
+	return value.Value
+}
+
+// getItemStart returns the (0-based) line of the dash of a sequence item, which is usually the first line of the item
+// but may also be a line of its own
+func getItemStart(lines []string, item *yaml.Node) int {
+	start := item.Line - 1
+	if !strings.HasPrefix(strings.TrimSpace(lines[start]), "-") && start > 0 && strings.TrimSpace(lines[start-1]) == "-" {
+		start--
+	}
+	return start
+}
+
+// getItemIndent returns the indentation of the sequence item starting in the given line
+func getItemIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " "))]
+}
+
+// lastLine returns the (1-based) last line of a node including its children and multi-line scalars
+func lastLine(node *yaml.Node) int {
+	if node == nil {
+		return 0
+	}
+
+	last := node.Line
+	if node.Kind == yaml.ScalarNode && (node.Style&(yaml.LiteralStyle|yaml.FoldedStyle)) != 0 {
+		last += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		if line := lastLine(child); line > last {
+			last = line
+		}
+	}
+	return last
+}
+
+// scrapeConfigsEqual returns true if both lists of scrape configs have the same YAML representation
+func scrapeConfigsEqual(a []*ScrapeConfig, b []*ScrapeConfig) bool {
+	contentA, errA := yamlv2.Marshal(a)
+	contentB, errB := yamlv2.Marshal(b)
+	return errA == nil && errB == nil && string(contentA) == string(contentB)
+}
@@ -0,0 +1,205 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// OperatorGroupVersion is the API group version of the Prometheus Operator CRDs
+const OperatorGroupVersion = "monitoring.coreos.com/v1"
+
+// ServiceMonitorResource identifies the ServiceMonitor CRD of the Prometheus Operator
+var ServiceMonitorResource = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors"}
+
+// PodMonitorResource identifies the PodMonitor CRD of the Prometheus Operator
+var PodMonitorResource = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "podmonitors"}
+
+// PrometheusRuleResource identifies the PrometheusRule CRD of the Prometheus Operator
+var PrometheusRuleResource = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "prometheusrules"}
+
+// AlertmanagerConfigGroupVersion is the API group version of the AlertmanagerConfig CRD of the Prometheus Operator
+const AlertmanagerConfigGroupVersion = "monitoring.coreos.com/v1alpha1"
+
+// AlertmanagerConfigResource identifies the AlertmanagerConfig CRD of the Prometheus Operator
+var AlertmanagerConfigResource = schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1alpha1", Resource: "alertmanagerconfigs"}
+
+// SecretResource identifies Kubernetes secrets, e.g., the secrets referenced by AlertmanagerConfigs
+var SecretResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// ManagedLabel marks the objects generated by prometheus-service
+const ManagedLabel = "keptn.sh/managed"
+
+// ProjectLabel, StageLabel and ServiceLabel identify the Keptn service an object has been generated for
+const (
+	ProjectLabel = "keptn.sh/project"
+	StageLabel   = "keptn.sh/stage"
+	ServiceLabel = "keptn.sh/service"
+)
+
+// OperatorHelper manages the custom resources of the Prometheus Operator
+type OperatorHelper struct {
+	DynamicClient dynamic.Interface
+	Discovery     discovery.DiscoveryInterface
+	// OverwriteUnmanaged allows Apply to update existing objects that do not carry the ManagedLabel
+	OverwriteUnmanaged bool
+}
+
+// NewOperatorHelper creates a new OperatorHelper
+func NewOperatorHelper(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface) *OperatorHelper {
+	return &OperatorHelper{DynamicClient: dynamicClient, Discovery: discoveryClient}
+}
+
+// IsInstalled returns true if the cluster serves all given resources of the Prometheus Operator API groups
+func (o *OperatorHelper) IsInstalled(resources ...schema.GroupVersionResource) (bool, error) {
+	served := map[schema.GroupVersionResource]bool{}
+	discovered := map[string]bool{}
+
+	for _, resource := range resources {
+		groupVersion := resource.GroupVersion().String()
+		if !discovered[groupVersion] {
+			discovered[groupVersion] = true
+
+			resourceList, err := o.Discovery.ServerResourcesForGroupVersion(groupVersion)
+			if k8serrors.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			for _, apiResource := range resourceList.APIResources {
+				served[resource.GroupVersion().WithResource(apiResource.Name)] = true
+			}
+		}
+
+		if !served[resource] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Apply creates the given object or updates its spec (or data), labels and annotations if it already exists. Existing
+// objects without the ManagedLabel are only updated if OverwriteUnmanaged is set. Updates are retried if the object has
+// been modified concurrently.
+func (o *OperatorHelper) Apply(resource schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	client := o.DynamicClient.Resource(resource).Namespace(obj.GetNamespace())
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := client.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+			if k8serrors.IsAlreadyExists(err) {
+				// created concurrently, the next attempt updates it
+				return k8serrors.NewConflict(resource.GroupResource(), obj.GetName(), err)
+			}
+			if err != nil {
+				return fmt.Errorf("unable to create %s %s/%s: %w", resource.Resource, obj.GetNamespace(), obj.GetName(), err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get %s %s/%s: %w", resource.Resource, obj.GetNamespace(), obj.GetName(), err)
+		}
+
+		if existing.GetLabels()[ManagedLabel] != "true" && !o.OverwriteUnmanaged {
+			return fmt.Errorf("%s %s/%s has not been created by prometheus-service and is not overwritten", resource.Resource, obj.GetNamespace(), obj.GetName())
+		}
+
+		existing.SetLabels(mergeStringMaps(existing.GetLabels(), obj.GetLabels()))
+		existing.SetAnnotations(mergeStringMaps(existing.GetAnnotations(), obj.GetAnnotations()))
+		for _, field := range []string{"spec", "data"} {
+			if value, ok := obj.Object[field]; ok {
+				existing.Object[field] = value
+			}
+		}
+
+		// conflicts are returned unwrapped so that they are retried
+		_, err = client.Update(context.TODO(), existing, metav1.UpdateOptions{})
+		if err != nil && !k8serrors.IsConflict(err) {
+			return fmt.Errorf("unable to update %s %s/%s: %w", resource.Resource, obj.GetNamespace(), obj.GetName(), err)
+		}
+		return err
+	})
+}
+
+// NewOperatorObject returns an unstructured Prometheus Operator object of the given kind
+func NewOperatorObject(kind string, namespace string, name string, labels map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": spec,
+		},
+	}
+	obj.SetAPIVersion(OperatorGroupVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
+	}
+	return obj
+}
+
+// NewSecretObject returns an unstructured secret containing the given data
+func NewSecretObject(namespace string, name string, labels map[string]string, data map[string]string) *unstructured.Unstructured {
+	encoded := map[string]interface{}{}
+	for key, value := range data {
+		encoded[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"type": "Opaque",
+			"data": encoded,
+		},
+	}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Secret")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
+	}
+	return obj
+}
+
+func mergeStringMaps(base map[string]string, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	result := map[string]string{}
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		result[key] = value
+	}
+	return result
+}
+
+// List returns the objects of the given resource in the namespace matching the label selector
+func (o *OperatorHelper) List(resource schema.GroupVersionResource, namespace string, labelSelector string) ([]unstructured.Unstructured, error) {
+	list, err := o.DynamicClient.Resource(resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %s in %s: %w", resource.Resource, namespace, err)
+	}
+	return list.Items, nil
+}
+
+// Delete deletes the object of the given resource, objects that do not exist are ignored
+func (o *OperatorHelper) Delete(resource schema.GroupVersionResource, namespace string, name string) error {
+	err := o.DynamicClient.Resource(resource).Namespace(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("unable to delete %s %s/%s: %w", resource.Resource, namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package prometheus
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeOperatorHelper(resources ...metav1.APIResource) *OperatorHelper {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		ServiceMonitorResource: "ServiceMonitorList",
+		PodMonitorResource:     "PodMonitorList",
+		PrometheusRuleResource: "PrometheusRuleList",
+	})
+
+	fake := &k8stesting.Fake{}
+	if len(resources) > 0 {
+		fake.Resources = []*metav1.APIResourceList{
+			{GroupVersion: OperatorGroupVersion, APIResources: resources},
+		}
+	}
+
+	return NewOperatorHelper(dynamicClient, &fakediscovery.FakeDiscovery{Fake: fake})
+}
+
+func TestOperatorHelper_IsInstalled(t *testing.T) {
+	helper := newFakeOperatorHelper()
+	installed, err := helper.IsInstalled(ServiceMonitorResource)
+	require.NoError(t, err)
+	assert.False(t, installed)
+
+	helper = newFakeOperatorHelper(metav1.APIResource{Name: "servicemonitors"})
+	installed, err = helper.IsInstalled(ServiceMonitorResource)
+	require.NoError(t, err)
+	assert.True(t, installed)
+
+	installed, err = helper.IsInstalled(ServiceMonitorResource, PrometheusRuleResource)
+	require.NoError(t, err)
+	assert.False(t, installed)
+
+	// the AlertmanagerConfig CRD is part of another API version
+	installed, err = helper.IsInstalled(ServiceMonitorResource, AlertmanagerConfigResource)
+	require.NoError(t, err)
+	assert.False(t, installed)
+
+	helper.Discovery.(*fakediscovery.FakeDiscovery).Resources = append(helper.Discovery.(*fakediscovery.FakeDiscovery).Resources, &metav1.APIResourceList{
+		GroupVersion: AlertmanagerConfigGroupVersion,
+		APIResources: []metav1.APIResource{{Name: "alertmanagerconfigs"}},
+	})
+	installed, err = helper.IsInstalled(ServiceMonitorResource, AlertmanagerConfigResource)
+	require.NoError(t, err)
+	assert.True(t, installed)
+}
+
+func TestOperatorHelper_Apply(t *testing.T) {
+	helper := newFakeOperatorHelper()
+	client := helper.DynamicClient.Resource(ServiceMonitorResource).Namespace("monitoring")
+
+	obj := NewOperatorObject("ServiceMonitor", "monitoring", "carts-sockshop-dev", map[string]string{ManagedLabel: "true"}, map[string]interface{}{
+		"endpoints": []interface{}{map[string]interface{}{"path": "/metrics"}},
+	})
+	require.NoError(t, helper.Apply(ServiceMonitorResource, obj))
+
+	created, err := client.Get(context.TODO(), "carts-sockshop-dev", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "ServiceMonitor", created.GetKind())
+	assert.Equal(t, map[string]string{ManagedLabel: "true"}, created.GetLabels())
+
+	// labels added by others are kept, the spec is replaced
+	created.SetLabels(map[string]string{ManagedLabel: "true", "team": "sockshop"})
+	_, err = client.Update(context.TODO(), created, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	obj = NewOperatorObject("ServiceMonitor", "monitoring", "carts-sockshop-dev", map[string]string{ManagedLabel: "true", "release": "prometheus"}, map[string]interface{}{
+		"endpoints": []interface{}{map[string]interface{}{"path": "/prometheus"}},
+	})
+	require.NoError(t, helper.Apply(ServiceMonitorResource, obj))
+
+	updated, err := client.Get(context.TODO(), "carts-sockshop-dev", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{ManagedLabel: "true", "release": "prometheus", "team": "sockshop"}, updated.GetLabels())
+	assert.Equal(t, obj.Object["spec"], updated.Object["spec"])
+}
+
+func TestOperatorHelper_ApplyUnmanaged(t *testing.T) {
+	helper := newFakeOperatorHelper()
+
+	unmanaged := NewOperatorObject("ServiceMonitor", "monitoring", "carts-sockshop-dev", nil, map[string]interface{}{"jobLabel": "custom"})
+	_, err := helper.DynamicClient.Resource(ServiceMonitorResource).Namespace("monitoring").Create(context.TODO(), unmanaged, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	obj := NewOperatorObject("ServiceMonitor", "monitoring", "carts-sockshop-dev", map[string]string{ManagedLabel: "true"}, map[string]interface{}{})
+	assert.Error(t, helper.Apply(ServiceMonitorResource, obj))
+
+	helper.OverwriteUnmanaged = true
+	require.NoError(t, helper.Apply(ServiceMonitorResource, obj))
+
+	updated, err := helper.DynamicClient.Resource(ServiceMonitorResource).Namespace("monitoring").Get(context.TODO(), "carts-sockshop-dev", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", updated.GetLabels()[ManagedLabel])
+}
+
+func TestOperatorHelper_ListAndDelete(t *testing.T) {
+	helper := newFakeOperatorHelper()
+
+	for _, name := range []string{"carts-sockshop-dev", "orders-sockshop-dev"} {
+		obj := NewOperatorObject("ServiceMonitor", "monitoring", name, map[string]string{ManagedLabel: "true", ProjectLabel: "sockshop", ServiceLabel: name[:strings.Index(name, "-")]}, map[string]interface{}{})
+		require.NoError(t, helper.Apply(ServiceMonitorResource, obj))
+	}
+
+	objects, err := helper.List(ServiceMonitorResource, "monitoring", ProjectLabel+"=sockshop,"+ServiceLabel+"=carts")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "carts-sockshop-dev", objects[0].GetName())
+
+	require.NoError(t, helper.Delete(ServiceMonitorResource, "monitoring", "carts-sockshop-dev"))
+	// deleting an object that does not exist is not an error
+	require.NoError(t, helper.Delete(ServiceMonitorResource, "monitoring", "carts-sockshop-dev"))
+
+	objects, err = helper.List(ServiceMonitorResource, "monitoring", ProjectLabel+"=sockshop")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "orders-sockshop-dev", objects[0].GetName())
+}
+
+func TestOperatorHelper_ApplySecret(t *testing.T) {
+	helper := newFakeOperatorHelper()
+	client := helper.DynamicClient.Resource(SecretResource).Namespace("monitoring")
+
+	require.NoError(t, helper.Apply(SecretResource, NewSecretObject("monitoring", "prometheus-service-webhook", map[string]string{ManagedLabel: "true"}, map[string]string{"token": "old"})))
+	require.NoError(t, helper.Apply(SecretResource, NewSecretObject("monitoring", "prometheus-service-webhook", map[string]string{ManagedLabel: "true"}, map[string]string{"token": "new"})))
+
+	secret, err := client.Get(context.TODO(), "prometheus-service-webhook", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Secret", secret.GetKind())
+	// the data of secrets is base64 encoded
+	assert.Equal(t, map[string]interface{}{"token": "bmV3"}, secret.Object["data"])
+}
+
+func TestOperatorHelper_ApplyRetriesOnConflict(t *testing.T) {
+	helper := newFakeOperatorHelper()
+	obj := NewOperatorObject("PrometheusRule", "monitoring", "carts-sockshop-dev-alerts", map[string]string{ManagedLabel: "true"}, map[string]interface{}{"groups": []interface{}{}})
+	require.NoError(t, helper.Apply(PrometheusRuleResource, obj))
+
+	conflicts := 0
+	helper.DynamicClient.(*dynamicfake.FakeDynamicClient).PrependReactor("update", "prometheusrules", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts < 2 {
+			conflicts++
+			return true, nil, k8serrors.NewConflict(PrometheusRuleResource.GroupResource(), "carts-sockshop-dev-alerts", nil)
+		}
+		return false, nil, nil
+	})
+
+	obj = NewOperatorObject("PrometheusRule", "monitoring", "carts-sockshop-dev-alerts", map[string]string{ManagedLabel: "true"}, map[string]interface{}{"groups": []interface{}{"updated"}})
+	require.NoError(t, helper.Apply(PrometheusRuleResource, obj))
+	assert.Equal(t, 2, conflicts)
+
+	updated, err := helper.DynamicClient.Resource(PrometheusRuleResource).Namespace("monitoring").Get(context.TODO(), "carts-sockshop-dev-alerts", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, obj.Object["spec"], updated.Object["spec"])
+}
@@ -1,11 +1,14 @@
 package prometheus
 
 import (
+	"strings"
+	"testing"
+	"time"
+
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
-	"testing"
 )
 
 const sampleConfigurationYAML = `global:
@@ -387,6 +390,132 @@ scrape_configs:
 
 	err = compareYamlEq(t, string(modifiedConfigYaml), resultingConfigYaml)
 	assert.NoError(t, err)
+
+	// patching the original document only appends the new scrape configs
+	patchedConfigYaml, err := PatchYamlConfiguration(yamlConfig, config)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(patchedConfigYaml, yamlConfig))
+
+	err = compareYamlEq(t, patchedConfigYaml, resultingConfigYaml)
+	assert.NoError(t, err)
+}
+
+func TestModificationPreservesCommentsAnchorsAndOrder(t *testing.T) {
+	yamlConfig := `# managed by the platform team
+global:
+  scrape_interval: 1m # default interval
+rule_files:
+  - /etc/config/recording_rules.yml
+scrape_configs:
+  # the Prometheus server itself
+  - job_name: prometheus
+    static_configs:
+      - targets: [localhost:9090]
+    tls_config: &tls
+      insecure_skip_verify: true
+  - job_name: carts-sockshop-dev
+    scrape_interval: 5s
+    static_configs:
+      - targets:
+          - carts.sockshop-dev:80
+
+  - job_name: carts-sockshop-staging
+    static_configs:
+      - targets:
+          - carts.sockshop-staging:80
+  # nodes
+  - job_name: kubernetes-nodes
+    tls_config: *tls
+    kubernetes_sd_configs:
+      - role: node
+alerting:
+  alertmanagers: []
+`
+
+	config, err := LoadYamlConfiguration(yamlConfig)
+	require.NoError(t, err)
+
+	// update carts-sockshop-dev, remove carts-sockshop-staging and add carts-sockshop-production
+	config.ScrapeConfigs[1].ScrapeInterval = model.Duration(10 * time.Second)
+	config.ScrapeConfigs = append(config.ScrapeConfigs[:2], config.ScrapeConfigs[3])
+	config.ScrapeConfigs = append(config.ScrapeConfigs, generateScrapeConfig("carts-sockshop-production", "carts.sockshop-production:80"))
+	config.RuleFiles = append(config.RuleFiles, "/etc/config/alerting_rules.yml")
+
+	patchedConfigYaml, err := PatchYamlConfiguration(yamlConfig, config)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# managed by the platform team
+global:
+  scrape_interval: 1m # default interval
+rule_files:
+  - /etc/config/recording_rules.yml
+  - /etc/config/alerting_rules.yml
+scrape_configs:
+  # the Prometheus server itself
+  - job_name: prometheus
+    static_configs:
+      - targets: [localhost:9090]
+    tls_config: &tls
+      insecure_skip_verify: true
+  - job_name: carts-sockshop-dev
+    honor_timestamps: false
+    scrape_interval: 10s
+    static_configs:
+    - targets:
+      - carts.sockshop-dev:80
+
+  # nodes
+  - job_name: kubernetes-nodes
+    tls_config: *tls
+    kubernetes_sd_configs:
+      - role: node
+  - job_name: carts-sockshop-production
+    honor_timestamps: false
+    scrape_interval: 5s
+    scrape_timeout: 3s
+    metrics_path: /metrics
+    static_configs:
+    - targets:
+      - carts.sockshop-production:80
+alerting:
+  alertmanagers: []
+`, patchedConfigYaml)
+
+	// the result is unchanged if nothing has been modified
+	config, err = LoadYamlConfiguration(yamlConfig)
+	require.NoError(t, err)
+	patchedConfigYaml, err = PatchYamlConfiguration(yamlConfig, config)
+	require.NoError(t, err)
+	assert.Equal(t, yamlConfig, patchedConfigYaml)
+}
+
+func TestModificationWithoutScrapeConfigs(t *testing.T) {
+	yamlConfig := "global:\n  scrape_interval: 1m\nscrape_configs: []\n# end of configuration\n"
+
+	config, err := LoadYamlConfiguration(yamlConfig)
+	require.NoError(t, err)
+	config.ScrapeConfigs = append(config.ScrapeConfigs, generateScrapeConfig("carts-sockshop-dev", "carts.sockshop-dev:80"))
+
+	patchedConfigYaml, err := PatchYamlConfiguration(yamlConfig, config)
+	require.NoError(t, err)
+	assert.Equal(t, `global:
+  scrape_interval: 1m
+scrape_configs:
+- job_name: carts-sockshop-dev
+  honor_timestamps: false
+  scrape_interval: 5s
+  scrape_timeout: 3s
+  metrics_path: /metrics
+  static_configs:
+  - targets:
+    - carts.sockshop-dev:80
+# end of configuration
+`, patchedConfigYaml)
+
+	// the section is appended if it does not exist
+	patchedConfigYaml, err = PatchYamlConfiguration("global:\n  scrape_interval: 1m", config)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(patchedConfigYaml, "global:\n  scrape_interval: 1m\nscrape_configs:\n- job_name: carts-sockshop-dev\n"))
 }
 
 func compareYamlEq(t *testing.T, a string, b string) error {
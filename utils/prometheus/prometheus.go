@@ -10,16 +10,15 @@ import (
 	"strings"
 	"time"
 
-	alertConfig "github.com/prometheus/alertmanager/config"
 	"github.com/prometheus/client_golang/api"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 
-	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
 )
@@ -57,32 +56,16 @@ type Handler struct {
 	PrometheusAPI  API
 	CustomFilters  []*keptnv2.SLIFilter
 	CustomQueries  map[string]string
+	// RecordedQueries maps queries to the selector of the series recorded for them by a recording rule
+	RecordedQueries map[string]string
 }
 
-const alertManagerYamlTemplate = `global:
-templates:
-- '/etc/alertmanager/*.tmpl'
-route:
-  receiver: keptn_integration
-  group_by: ['alertname', 'priority']
-  group_wait: 10s
-  repeat_interval: 30m
-  routes:
-    - receiver: keptn_integration
-    # Send severity=webhook alerts to the webhook
-      match:
-        severity: webhook
-      group_wait: 10s
-      repeat_interval: 1m
-
-receivers:
-- name: keptn_integration
-  webhook_configs:
-  - url: http://prometheus-service.%s.svc.cluster.local:8080`
-
 type PrometheusHelper struct {
-	KubeAPI   *kubernetes.Clientset
+	KubeAPI   kubernetes.Interface
 	Namespace string
+	// Integration describes the receiver and route added to the Alertmanager configuration, the default integration
+	// for the namespace is used if it is not set
+	Integration *AlertManagerIntegration
 }
 
 // NewPrometheusHelper creates a new PrometheusHelper
@@ -123,51 +106,48 @@ func (p *PrometheusHelper) CreateConfigMap(cm *v1.ConfigMap, namespace string) e
 	return nil
 }
 
-func generateAlertManagerYaml(namespace string) string {
-	return fmt.Sprintf(alertManagerYamlTemplate, namespace)
+// UpdateAMConfigMap adds the receiver forwarding alerts to prometheus-service to the Alertmanager configuration, the configmap is read and
+// modified again if it has been updated concurrently
+func (p *PrometheusHelper) UpdateAMConfigMap(name string, filename string, namespace string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		getCM, err := p.GetConfigMap(name, namespace)
+		if err != nil {
+			return err
+		}
+
+		updatedConfig, changed, err := p.addKeptnIntegration(getCM.Data[filename])
+		if err != nil || !changed {
+			return err
+		}
+
+		getCM.Data[filename] = updatedConfig
+		return p.UpdateConfigMap(getCM, namespace)
+	})
 }
 
-func (p *PrometheusHelper) UpdateAMConfigMap(name string, filename string, namespace string) error {
+// DiffAMConfigMap returns the unified diff of the changes UpdateAMConfigMap would apply to the Alertmanager
+// configuration without applying them
+func (p *PrometheusHelper) DiffAMConfigMap(name string, filename string, namespace string) (string, error) {
 	getCM, err := p.GetConfigMap(name, namespace)
 	if err != nil {
-		return err
-	}
-
-	var config alertConfig.Config
-	err = yaml.Unmarshal([]byte(getCM.Data[filename]), &config)
-	if err != nil {
-		return err
+		return "", err
 	}
 
-	var keptnAlertConfig alertConfig.Config
-	err = yaml.Unmarshal([]byte(generateAlertManagerYaml(p.Namespace)), &keptnAlertConfig)
+	updatedConfig, _, err := p.addKeptnIntegration(getCM.Data[filename])
 	if err != nil {
-		return err
-	}
-
-	// go over all receivers and check if keptn_integration is already there
-	for _, rec := range config.Receivers {
-		if rec.Name == "keptn_integration" {
-			// already present, don't do anything
-			return nil
-		}
+		return "", err
 	}
+	return UnifiedDiff(filename, getCM.Data[filename], updatedConfig)
+}
 
-	// go over all routes and check if keptn_integration is already there
-	for _, route := range config.Route.Routes {
-		if route.Receiver == "keptn_integration" {
-			// already present, don't do anything
-			return nil
-		}
+// addKeptnIntegration returns the given Alertmanager configuration extended by the receiver and route forwarding
+// alerts to prometheus-service and whether it has been changed
+func (p *PrometheusHelper) addKeptnIntegration(content string) (string, bool, error) {
+	integration := NewAlertManagerIntegration(p.Namespace)
+	if p.Integration != nil {
+		integration = *p.Integration
 	}
-
-	// insert keptn_integration in receivers and templates
-	config.Receivers = append(config.Receivers, keptnAlertConfig.Receivers...)
-	config.Templates = append(config.Templates, keptnAlertConfig.Templates...)
-	config.Route.Routes = append(config.Route.Routes, keptnAlertConfig.Route.Routes...)
-	getCM.Data[filename] = fmt.Sprint(config)
-
-	return p.UpdateConfigMap(getCM, namespace)
+	return MergeAlertManagerConfig(content, integration)
 }
 
 // NewPrometheusHandler returns a new prometheus handler that interacts with the Prometheus REST API
@@ -192,64 +172,116 @@ func NewPrometheusHandler(apiURL string, eventData *keptnv2.EventData, deploymen
 	return ph
 }
 
+// SLIValue holds the value of an SLI together with information on how it has been retrieved
+type SLIValue struct {
+	Value     float64
+	Query     string
+	Timestamp time.Time
+	Warnings  []string
+}
+
 // GetSLIValue retrieves the specified value via the Prometheus API
 func (ph *Handler) GetSLIValue(metric string, start string, end string) (float64, error) {
+	sliValue, err := ph.QuerySLIValue(metric, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return sliValue.Value, nil
+}
+
+// QuerySLIValue retrieves the specified value via the Prometheus API and returns it together with the rendered query,
+// the evaluation timestamp and any warnings reported by the API. If an error occurs after the query has been rendered,
+// the returned SLIValue still contains the query details. If the series recorded for the query has no values, the
+// query itself is evaluated instead.
+func (ph *Handler) QuerySLIValue(metric string, start string, end string) (*SLIValue, error) {
 	startUnix, err := parseUnixTimestamp(start)
 	if err != nil {
-		return 0, fmt.Errorf("unable to parse start timestamp: %w", err)
+		return nil, fmt.Errorf("unable to parse start timestamp: %w", err)
 	}
-	endUnix, _ := parseUnixTimestamp(end)
+	endUnix, err := parseUnixTimestamp(end)
 	if err != nil {
-		return 0, fmt.Errorf("unable to parse end timestamp: %w", err)
+		return nil, fmt.Errorf("unable to parse end timestamp: %w", err)
 	}
 	query, err := ph.GetMetricQuery(metric, startUnix, endUnix)
 	if err != nil {
-		return 0, fmt.Errorf("unable to get metriy query: %w", err)
+		return nil, fmt.Errorf("unable to get metriy query: %w", err)
 	}
 
-	log.Println("GetSLIValue: Generated query: /api/v1/query?query=" + query + "&time=" + strconv.FormatInt(endUnix.Unix(), 10))
+	sliValue, err := ph.queryValue(metric, query, endUnix)
+	if errors.Is(err, ErrNoValues) {
+		// the recorded series might not exist yet (e.g., the recording rules have not been evaluated or deployed)
+		rawQuery, rawErr := ph.getMetricQuery(metric, startUnix, endUnix)
+		if rawErr == nil && rawQuery != query {
+			log.Printf("Recorded series %s returned no values, falling back to query %s", query, rawQuery)
+			return ph.queryValue(metric, rawQuery, endUnix)
+		}
+	}
+	return sliValue, err
+}
 
-	result, w, err := ph.PrometheusAPI.Query(context.TODO(), query, endUnix)
-	if err != nil {
-		return 0, fmt.Errorf("unable to query prometheus api: %w", err)
+// queryValue evaluates the rendered query at the given time and returns its single value
+func (ph *Handler) queryValue(metric string, query string, endUnix time.Time) (*SLIValue, error) {
+	sliValue := &SLIValue{
+		Query:     query,
+		Timestamp: endUnix,
 	}
 
+	log.Println("GetSLIValue: Generated query: /api/v1/query?query=" + query + "&time=" + strconv.FormatInt(endUnix.Unix(), 10))
+
+	result, w, err := ph.PrometheusAPI.Query(ContextWithIndicator(context.TODO(), metric), query, endUnix)
 	if len(w) != 0 {
 		log.Printf("Prometheus API returned warnings: %v", w)
+		sliValue.Warnings = w
+	}
+	if err != nil {
+		return sliValue, fmt.Errorf("unable to query prometheus api: %w", err)
 	}
 
 	// check if we can cast the result to a vector, it might be another data struct which we can't process
 	resultVector, ok := result.(model.Vector)
 	if !ok {
-		return 0, fmt.Errorf("prometheus api response is not a Vector: %v", result)
+		return sliValue, fmt.Errorf("prometheus api response is not a Vector: %v", result)
 	}
 
 	// We are only allowed to return one value, if not the query may be malformed
 	// we are using two different errors to give the user more information about the result
 	if len(resultVector) == 0 {
-		return 0, ErrNoValues
+		return sliValue, ErrNoValues
 	} else if len(resultVector) > 1 {
-		return 0, ErrMultipleValues
+		return sliValue, ErrMultipleValues
 	}
 
 	// parse the first entry as float and return the value if it's a valid float value
 	resultValue := resultVector[0].Value.String()
 	floatValue, err := strconv.ParseFloat(resultValue, 64)
 	if err != nil || math.IsNaN(floatValue) {
-		return 0, ErrInvalidData
+		return sliValue, ErrInvalidData
 	}
 
 	log.Printf(fmt.Sprintf("Prometheus Result is %v\n", floatValue))
-	return floatValue, nil
+	sliValue.Value = floatValue
+	return sliValue, nil
 }
 
-// GetMetricQuery returns the prometheus metric expression for the given SLI, start and end time
+// GetMetricQuery returns the prometheus metric expression for the given SLI, start and end time. If the query is
+// recorded by a recording rule, the selector of the recorded series is returned instead.
 func (ph *Handler) GetMetricQuery(metric string, start time.Time, end time.Time) (string, error) {
+	query, err := ph.getMetricQuery(metric, start, end)
+	if err != nil {
+		return "", err
+	}
+
+	if recorded, ok := ph.RecordedQueries[query]; ok {
+		return recorded, nil
+	}
+	return query, nil
+}
+
+// getMetricQuery returns the prometheus metric expression for the given SLI, start and end time
+func (ph *Handler) getMetricQuery(metric string, start time.Time, end time.Time) (string, error) {
 	query := ph.CustomQueries[metric]
 	if query != "" {
-		query = ph.replaceQueryParameters(query, start, end)
-
-		return query, nil
+		return ph.replaceQueryParameters(query, start, end), nil
 	}
 
 	switch metric {
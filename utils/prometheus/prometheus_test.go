@@ -111,3 +111,53 @@ func TestHandler_GetSLIValueError(t *testing.T) {
 	require.Error(t, err)
 	require.ErrorIs(t, err, apiError)
 }
+
+func TestHandler_GetMetricQueryRecorded(t *testing.T) {
+	handler := Handler{
+		Service:       "carts",
+		CustomQueries: map[string]string{"error_rate": "sum(rate(errors{service=\"$SERVICE\"}[$DURATION_SECONDS]))"},
+		RecordedQueries: map[string]string{
+			"sum(rate(errors{service=\"carts\"}[300s]))": "keptn_sli:error_rate:5m{service=\"carts\"}",
+		},
+	}
+	end := time.Now()
+
+	query, err := handler.GetMetricQuery("error_rate", end.Add(-5*time.Minute), end)
+	require.NoError(t, err)
+	require.Equal(t, "keptn_sli:error_rate:5m{service=\"carts\"}", query)
+
+	// other windows are not recorded
+	query, err = handler.GetMetricQuery("error_rate", end.Add(-10*time.Minute), end)
+	require.NoError(t, err)
+	require.Equal(t, "sum(rate(errors{service=\"carts\"}[600s]))", query)
+}
+
+func TestHandler_QuerySLIValueRecordedFallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	apiMock := prometheusfake.NewMockAPI(mockCtrl)
+	handler := Handler{
+		Service:       "carts",
+		PrometheusAPI: apiMock,
+		CustomQueries: map[string]string{"error_rate": "sum(rate(errors{service=\"$SERVICE\"}[$DURATION_SECONDS]))"},
+		RecordedQueries: map[string]string{
+			"sum(rate(errors{service=\"carts\"}[300s]))": "keptn_sli:error_rate:5m{service=\"carts\"}",
+		},
+	}
+
+	// the recorded series does not exist yet, e.g., because the recording rule has not been evaluated
+	gomock.InOrder(
+		apiMock.EXPECT().Query(gomock.Any(), "keptn_sli:error_rate:5m{service=\"carts\"}", gomock.Any()).Return(prometheusModel.Vector{}, prometheusAPI.Warnings{}, nil).Times(1),
+		apiMock.EXPECT().Query(gomock.Any(), "sum(rate(errors{service=\"carts\"}[300s]))", gomock.Any()).Return(prometheusModel.Vector{{Value: 0.5}}, prometheusAPI.Warnings{}, nil).Times(1),
+	)
+
+	end := time.Now()
+	startTime := end.Add(-5 * time.Minute).Format(time.RFC3339)
+	endTime := end.Format(time.RFC3339)
+
+	sliValue, err := handler.QuerySLIValue("error_rate", startTime, endTime)
+	require.NoError(t, err)
+	require.Equal(t, 0.5, sliValue.Value)
+	require.Equal(t, "sum(rate(errors{service=\"carts\"}[300s]))", sliValue.Query)
+}
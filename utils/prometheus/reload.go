@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigReloader triggers configuration reloads of Prometheus or Alertmanager via their /-/reload endpoint and waits
+// until the expected configuration is active. The reload is repeated, since the configmap is only visible to Prometheus
+// after the kubelet has synced the mounted volume.
+type ConfigReloader struct {
+	Client   *http.Client
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// NewConfigReloader creates a new ConfigReloader waiting at most timeout for the configuration to become active
+func NewConfigReloader(timeout time.Duration) *ConfigReloader {
+	return &ConfigReloader{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Interval: 10 * time.Second,
+		Timeout:  timeout,
+	}
+}
+
+// ReloadPrometheus reloads the Prometheus at the given URL until all given scrape jobs are part of the configuration
+// returned by /api/v1/status/config
+func (r *ConfigReloader) ReloadPrometheus(prometheusURL string, jobNames []string) error {
+	return r.reloadUntil(prometheusURL, "/api/v1/status/config", func(body []byte) error {
+		status := struct {
+			Data struct {
+				YAML string `json:"yaml"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(body, &status); err != nil {
+			return err
+		}
+
+		config, err := LoadYamlConfiguration(status.Data.YAML)
+		if err != nil {
+			return err
+		}
+
+		active := map[string]bool{}
+		for _, scrapeConfig := range config.ScrapeConfigs {
+			active[scrapeConfig.JobName] = true
+		}
+
+		var missing []string
+		for _, jobName := range jobNames {
+			if !active[jobName] {
+				missing = append(missing, jobName)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("scrape jobs %s are not active", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// ReloadAlertManager reloads the Alertmanager at the given URL until the receiver is part of the configuration
+// returned by /api/v2/status
+func (r *ConfigReloader) ReloadAlertManager(alertManagerURL string, receiver string) error {
+	return r.reloadUntil(alertManagerURL, "/api/v2/status", func(body []byte) error {
+		status := struct {
+			Config struct {
+				Original string `json:"original"`
+			} `json:"config"`
+		}{}
+		if err := json.Unmarshal(body, &status); err != nil {
+			return err
+		}
+
+		config := struct {
+			Receivers []struct {
+				Name string `yaml:"name"`
+			} `yaml:"receivers"`
+		}{}
+		if err := yaml.Unmarshal([]byte(status.Config.Original), &config); err != nil {
+			return err
+		}
+
+		for _, rec := range config.Receivers {
+			if rec.Name == receiver {
+				return nil
+			}
+		}
+		return fmt.Errorf("receiver %s is not active", receiver)
+	})
+}
+
+// reloadUntil triggers a reload and checks the status endpoint until checkStatus succeeds or the timeout is reached.
+// A failing reload is returned immediately, e.g., if the lifecycle API is disabled or the configuration is invalid.
+func (r *ConfigReloader) reloadUntil(baseURL string, statusPath string, checkStatus func(body []byte) error) error {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	deadline := time.Now().Add(r.Timeout)
+
+	for {
+		if _, err := r.do(http.MethodPost, baseURL+"/-/reload"); err != nil {
+			return fmt.Errorf("reload failed: %w", err)
+		}
+
+		body, err := r.do(http.MethodGet, baseURL+statusPath)
+		if err == nil {
+			err = checkStatus(body)
+		}
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(r.Interval).After(deadline) {
+			return fmt.Errorf("configuration is not active after %s: %w", r.Timeout, err)
+		}
+		time.Sleep(r.Interval)
+	}
+}
+
+func (r *ConfigReloader) do(method string, url string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(body))
+		if message == "" {
+			return nil, errors.New(resp.Status)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, message)
+	}
+	return body, nil
+}
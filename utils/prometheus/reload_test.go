@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReloader() *ConfigReloader {
+	return &ConfigReloader{Client: http.DefaultClient, Interval: time.Millisecond, Timeout: 100 * time.Millisecond}
+}
+
+func TestConfigReloader_ReloadPrometheus(t *testing.T) {
+	reloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/-/reload":
+			assert.Equal(t, http.MethodPost, r.Method)
+			reloads++
+		case "/api/v1/status/config":
+			config := "scrape_configs:\n- job_name: prometheus\n"
+			// the updated configmap is only visible after the second reload
+			if reloads >= 2 {
+				config += "- job_name: carts-sockshop-dev\n"
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   map[string]string{"yaml": config},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	require.NoError(t, newTestReloader().ReloadPrometheus(server.URL, []string{"carts-sockshop-dev"}))
+	assert.Equal(t, 2, reloads)
+}
+
+func TestConfigReloader_ReloadPrometheusTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/status/config" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"yaml":"scrape_configs: []"}}`))
+		}
+	}))
+	defer server.Close()
+
+	err := newTestReloader().ReloadPrometheus(server.URL, []string{"carts-sockshop-dev"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scrape jobs carts-sockshop-dev are not active")
+}
+
+func TestConfigReloader_ReloadDisabledLifecycleAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("Lifecycle API is not enabled."))
+	}))
+	defer server.Close()
+
+	err := newTestReloader().ReloadPrometheus(server.URL, nil)
+	require.Error(t, err)
+	assert.Equal(t, "reload failed: 403 Forbidden: Lifecycle API is not enabled.", err.Error())
+}
+
+func TestConfigReloader_ReloadAlertManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/status" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"config": map[string]string{"original": "receivers:\n- name: default\n- name: keptn_integration\n"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	require.NoError(t, newTestReloader().ReloadAlertManager(server.URL+"/", "keptn_integration"))
+	assert.Error(t, newTestReloader().ReloadAlertManager(server.URL, "other"))
+}
@@ -0,0 +1,130 @@
+package prometheus
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-kit/log"
+	alertConfig "github.com/prometheus/alertmanager/config"
+	promConfig "github.com/prometheus/prometheus/config"
+	// register the service discovery configs known to Prometheus, otherwise the config loader rejects them
+	_ "github.com/prometheus/prometheus/discovery/install"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v2"
+)
+
+// ValidationError contains all problems found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// ValidateConfigYAML validates the given prometheus.yml with the Prometheus config loader
+func ValidateConfigYAML(content string) error {
+	if _, err := promConfig.Load(content, false, log.NewNopLogger()); err != nil {
+		return &ValidationError{Problems: []string{err.Error()}}
+	}
+	return nil
+}
+
+// ValidateRulesYAML validates the given rule file with the Prometheus rule parser, which also parses the expressions
+// of the rules with the PromQL parser. Fields unknown to the parser (e.g., fields of newer Prometheus versions like
+// keep_firing_for or fields of Thanos like partial_response_strategy) are ignored.
+func ValidateRulesYAML(content string) error {
+	known, err := removeUnknownRuleFields([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	_, errs := rulefmt.Parse(known)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	validationErr := &ValidationError{}
+	for _, err := range errs {
+		validationErr.Problems = append(validationErr.Problems, err.Error())
+	}
+	return validationErr
+}
+
+// removeUnknownRuleFields removes the fields of the groups and rules that are unknown to the Prometheus rule parser.
+// The content is returned unchanged if there are no such fields, which keeps the line numbers of the parser errors.
+func removeUnknownRuleFields(content []byte) ([]byte, error) {
+	var ruleFile yaml.MapSlice
+	if err := yaml.Unmarshal(content, &ruleFile); err != nil {
+		return nil, err
+	}
+
+	groupFields := yamlFieldNames(reflect.TypeOf(rulefmt.RuleGroup{}))
+	ruleFields := yamlFieldNames(reflect.TypeOf(rulefmt.Rule{}))
+	removed := false
+
+	for _, item := range ruleFile {
+		if item.Key != "groups" {
+			continue
+		}
+		groups, _ := item.Value.([]interface{})
+		for i := range groups {
+			group, ok := groups[i].(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+			group, removed = removeUnknownFields(group, groupFields, removed)
+			groups[i] = group
+
+			for _, groupItem := range group {
+				if groupItem.Key != "rules" {
+					continue
+				}
+				rules, _ := groupItem.Value.([]interface{})
+				for j := range rules {
+					if rule, ok := rules[j].(yaml.MapSlice); ok {
+						rules[j], removed = removeUnknownFields(rule, ruleFields, removed)
+					}
+				}
+			}
+		}
+	}
+
+	if !removed {
+		return content, nil
+	}
+	return yaml.Marshal(ruleFile)
+}
+
+// removeUnknownFields returns the fields of the mapping contained in the given field names and whether any field has
+// been removed so far
+func removeUnknownFields(mapping yaml.MapSlice, names map[string]bool, removed bool) (yaml.MapSlice, bool) {
+	var known yaml.MapSlice
+	for _, item := range mapping {
+		if names[fmt.Sprint(item.Key)] {
+			known = append(known, item)
+		} else {
+			removed = true
+		}
+	}
+	return known, removed
+}
+
+// yamlFieldNames returns the names of the YAML fields of the given struct type
+func yamlFieldNames(structType reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		name := strings.Split(structType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// ValidateAlertManagerConfigYAML validates the given alertmanager.yml with the Alertmanager config loader
+func ValidateAlertManagerConfigYAML(content string) error {
+	_, err := alertConfig.Load(content)
+	return err
+}
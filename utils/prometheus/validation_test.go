@@ -0,0 +1,183 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigYAML(t *testing.T) {
+	valid := `
+global:
+  scrape_interval: 15s
+  scrape_timeout: 10s
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    scrape_interval: 5s
+    metrics_path: /metrics
+    static_configs:
+      - targets: ['carts.sockshop-dev:80']
+        labels:
+          keptn_managed: 'true'
+  - job_name: carts-sockshop-production
+    kubernetes_sd_configs:
+      - role: endpoints
+    relabel_configs:
+      - source_labels: [__meta_kubernetes_service_name]
+        regex: carts
+        action: keep
+      - target_label: keptn_managed
+        replacement: 'true'
+`
+	require.NoError(t, ValidateConfigYAML(valid))
+
+	invalid := map[string]string{
+		"scrape timeout greater than scrape interval": `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    scrape_interval: 5s
+    scrape_timeout: 10s
+`,
+		"is not a valid hostname": `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    static_configs:
+      - targets: ['http://carts.sockshop-dev:80']
+`,
+		`found multiple scrape configs with job name "carts-sockshop-dev"`: `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+  - job_name: carts-sockshop-dev
+`,
+		`unknown Kubernetes SD role "deployment"`: `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    kubernetes_sd_configs:
+      - role: deployment
+`,
+		"error parsing regexp": `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    relabel_configs:
+      - regex: carts(
+        action: keep
+`,
+		"relabel configuration for replace action requires 'target_label' value": `
+scrape_configs:
+  - job_name: carts-sockshop-dev
+    relabel_configs:
+      - action: replace
+`,
+	}
+	for problem, config := range invalid {
+		err := ValidateConfigYAML(config)
+		require.Error(t, err, problem)
+
+		validationErr, ok := err.(*ValidationError)
+		require.True(t, ok)
+		require.Len(t, validationErr.Problems, 1)
+		assert.Contains(t, validationErr.Problems[0], problem)
+	}
+
+	// scrape configs using other service discovery mechanisms are known to the config loader
+	require.NoError(t, ValidateConfigYAML(`
+scrape_configs:
+  - job_name: consul
+    consul_sd_configs:
+      - server: consul:8500
+  - job_name: dns
+    dns_sd_configs:
+      - names: [carts.sockshop-dev.svc]
+        type: A
+        port: 80
+`))
+}
+
+func TestValidateRulesYAML(t *testing.T) {
+	valid := `
+groups:
+  - name: carts sockshop-dev alerts
+    rules:
+      - alert: response_time_p95
+        expr: histogram_quantile(0.95, sum(rate(http_response_time_milliseconds_bucket{job='carts-sockshop-dev-primary'}[180s])) by (le))>200
+        for: 10m
+        labels:
+          severity: webhook
+          pod_name: ""
+        annotations:
+          summary: response_time_p95
+          descriptions: Pod name {{ $labels.pod_name }}
+      - record: job:http_requests:rate5m
+        expr: sum(rate(http_requests_total[5m])) by (job)
+`
+	require.NoError(t, ValidateRulesYAML(valid))
+
+	invalid := `
+groups:
+  - name: carts sockshop-dev alerts
+    rules:
+      - alert: response_time_p95
+        expr: histogram_quantile(0.95, sum(rate(http_response_time_milliseconds_bucket[180s]) by (le)>200
+        for: 10 minutes
+        annotations:
+          summary: '{{ $labels.pod_name '
+      - record: job:http_requests:rate5m
+        alert: both
+        expr: ""
+  - name: carts sockshop-dev alerts
+`
+	err := ValidateRulesYAML(invalid)
+	require.Error(t, err)
+	problems := err.(*ValidationError).Problems
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], `not a valid duration string: "10 minutes"`)
+
+	invalid = `
+groups:
+  - name: carts sockshop-dev alerts
+    rules:
+      - alert: response_time_p95
+        expr: histogram_quantile(0.95, sum(rate(http_response_time_milliseconds_bucket[180s]) by (le)>200
+        annotations:
+          summary: '{{ $labels.pod_name '
+      - record: job:http_requests:rate5m
+        alert: both
+        expr: ""
+  - name: carts sockshop-dev alerts
+`
+	err = ValidateRulesYAML(invalid)
+	require.Error(t, err)
+	problems = err.(*ValidationError).Problems
+	require.Len(t, problems, 5)
+	assert.Contains(t, problems[0], `group "carts sockshop-dev alerts", rule 1, "response_time_p95": could not parse expression`)
+	assert.Contains(t, problems[1], `group "carts sockshop-dev alerts", rule 1, "response_time_p95": annotation "summary"`)
+	assert.Contains(t, problems[2], `group "carts sockshop-dev alerts", rule 2, "both": only one of 'record' and 'alert' must be set`)
+	assert.Contains(t, problems[3], `group "carts sockshop-dev alerts", rule 2, "both": field 'expr' must be set in rule`)
+	assert.Contains(t, problems[4], `groupname: "carts sockshop-dev alerts" is repeated in the same file`)
+
+	// fields unknown to the Prometheus rule parser (e.g., of newer Prometheus versions or Thanos) are ignored
+	require.NoError(t, ValidateRulesYAML(`
+groups:
+  - name: test
+    partial_response_strategy: warn
+    rules:
+      - alert: test
+        expr: up == 0
+        keep_firing_for: 5m
+`))
+	assert.Error(t, ValidateRulesYAML(`
+groups:
+  - name: test
+    partial_response_strategy: warn
+    rules:
+      - alert: test
+        expr: up ==
+        keep_firing_for: 5m
+`))
+}
+
+func TestValidateAlertManagerConfigYAML(t *testing.T) {
+	assert.NoError(t, ValidateAlertManagerConfigYAML(testAlertManagerYaml))
+	assert.Error(t, ValidateAlertManagerConfigYAML("route:\n  receiver: missing\nreceivers:\n- name: default\n"))
+}